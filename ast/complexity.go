@@ -0,0 +1,38 @@
+package ast
+
+// ComplexityMetrics describes size of an expression.
+type ComplexityMetrics struct {
+	Depth         int // maximum nesting of nodes, 1 for a single node
+	OperatorCount int // unary and binary operators
+	CallCount     int // calls of functions and builtins
+	ClosureCount  int // predicates of builtins and functions defined in expression
+}
+
+// Complexity computes metrics of tree in a single traversal.
+func Complexity(node Node) ComplexityMetrics {
+	var m ComplexityMetrics
+	m.Depth = complexity(node, &m)
+	return m
+}
+
+// complexity accumulates counts of node into m and returns its depth.
+func complexity(node Node, m *ComplexityMetrics) int {
+	if node == nil {
+		return 0
+	}
+	switch node.(type) {
+	case *UnaryNode, *BinaryNode:
+		m.OperatorCount++
+	case *CallNode, *BuiltinNode:
+		m.CallCount++
+	case *ClosureNode, *FunctionNode:
+		m.ClosureCount++
+	}
+	depth := 0
+	for _, child := range Children(node) {
+		if d := complexity(child, m); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
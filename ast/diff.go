@@ -0,0 +1,233 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DiffKind is kind of DiffEntry.
+type DiffKind int
+
+const (
+	Added DiffKind = iota
+	Removed
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// DiffEntry is a single difference between two trees. Before is nil for
+// added nodes and After is nil for removed ones. Parent is the node of the
+// after tree containing the difference, nil for the root.
+type DiffEntry struct {
+	Kind   DiffKind
+	Before Node
+	After  Node
+	Parent Node
+}
+
+func (e DiffEntry) String() string {
+	switch e.Kind {
+	case Added:
+		return fmt.Sprintf("added %s", e.After)
+	case Removed:
+		return fmt.Sprintf("removed %s", e.Before)
+	}
+	return fmt.Sprintf("changed %s to %s", e.Before, e.After)
+}
+
+// Diff returns semantic differences between before and after trees.
+// Operands of && and || are compared regardless of their order.
+func Diff(before, after Node) []DiffEntry {
+	d := &differ{}
+	d.diff(before, after, nil)
+	return d.entries
+}
+
+type differ struct {
+	entries []DiffEntry
+}
+
+func (d *differ) add(kind DiffKind, before, after, parent Node) {
+	d.entries = append(d.entries, DiffEntry{Kind: kind, Before: before, After: after, Parent: parent})
+}
+
+func (d *differ) diff(before, after, parent Node) {
+	switch {
+	case before == nil && after == nil:
+		return
+	case before == nil:
+		d.add(Added, nil, after, parent)
+		return
+	case after == nil:
+		d.add(Removed, before, nil, parent)
+		return
+	case before.String() == after.String():
+		return
+	}
+
+	op, ok := logical(before)
+	if !ok {
+		op, ok = logical(after)
+	}
+	if ok {
+		d.operands(flatten(before, op), flatten(after, op), after)
+		return
+	}
+
+	if reflect.TypeOf(before) != reflect.TypeOf(after) || head(before) != head(after) {
+		d.add(Changed, before, after, parent)
+		return
+	}
+	a, b := Children(before), Children(after)
+	if len(a) != len(b) {
+		d.add(Changed, before, after, parent)
+		return
+	}
+	for i := range a {
+		d.diff(a[i], b[i], after)
+	}
+}
+
+// operands compares operands of logical operator. Equal operands are
+// skipped, remaining ones are paired if they look at the same value.
+func (d *differ) operands(before, after []Node, parent Node) {
+	matched := make([]bool, len(after))
+	var removed []Node
+outer:
+	for _, b := range before {
+		for j, a := range after {
+			if !matched[j] && a.String() == b.String() {
+				matched[j] = true
+				continue outer
+			}
+		}
+		removed = append(removed, b)
+	}
+	for _, b := range removed {
+		paired := false
+		for j, a := range after {
+			if !matched[j] && similar(b, a) {
+				matched[j] = true
+				paired = true
+				d.diff(b, a, parent)
+				break
+			}
+		}
+		if !paired {
+			d.add(Removed, b, nil, parent)
+		}
+	}
+	for j, a := range after {
+		if !matched[j] {
+			d.add(Added, nil, a, parent)
+		}
+	}
+}
+
+// similar reports whether both nodes are binary operations on the same
+// left operand, like "amount > 100" and "amount >= 90".
+func similar(a, b Node) bool {
+	x, ok := a.(*BinaryNode)
+	if !ok {
+		return false
+	}
+	y, ok := b.(*BinaryNode)
+	if !ok {
+		return false
+	}
+	return x.Left.String() == y.Left.String()
+}
+
+func logical(node Node) (string, bool) {
+	if n, ok := node.(*BinaryNode); ok {
+		switch n.Operator {
+		case "&&", "and":
+			return "&&", true
+		case "||", "or":
+			return "||", true
+		}
+	}
+	return "", false
+}
+
+func flatten(node Node, op string) []Node {
+	if o, ok := logical(node); ok && o == op {
+		n := node.(*BinaryNode)
+		return append(flatten(n.Left, op), flatten(n.Right, op)...)
+	}
+	return []Node{node}
+}
+
+// head returns part of node which is not a child node.
+func head(node Node) string {
+	switch n := node.(type) {
+	case *UnaryNode:
+		return n.Operator
+	case *BinaryNode:
+		return n.Operator
+	case *MemberNode:
+		return fmt.Sprint(n.Optional)
+	case *BuiltinNode:
+		return n.Name
+	case *FunctionNode:
+		return fmt.Sprint(n.Name, n.Params)
+	case *VariableDeclaratorNode:
+		return n.Name
+	case *IdentifierNode, *IntegerNode, *FloatNode, *BoolNode, *StringNode,
+		*ConstantNode, *PointerNode, *NilNode:
+		// Leaves are equal only if they print the same.
+		return n.String()
+	}
+	return ""
+}
+
+// Children returns direct child nodes of node. Absent optional children,
+// like bounds of slices, are nil.
+func Children(node Node) []Node {
+	switch n := node.(type) {
+	case *UnaryNode:
+		return []Node{n.Node}
+	case *BinaryNode:
+		return []Node{n.Left, n.Right}
+	case *ChainNode:
+		return []Node{n.Node}
+	case *MemberNode:
+		return []Node{n.Node, n.Property}
+	case *SliceNode:
+		return []Node{n.Node, n.From, n.To}
+	case *CallNode:
+		return append([]Node{n.Callee}, n.Arguments...)
+	case *BuiltinNode:
+		return n.Arguments
+	case *ClosureNode:
+		return []Node{n.Node}
+	case *FunctionNode:
+		return []Node{n.Body}
+	case *ConditionalNode:
+		return []Node{n.Cond, n.Exp1, n.Exp2}
+	case *VariableDeclaratorNode:
+		return []Node{n.Value, n.Expr}
+	case *BlockNode:
+		return append(append([]Node{}, n.Statements...), n.Result)
+	case *ArrayNode:
+		return n.Nodes
+	case *MapNode:
+		return n.Pairs
+	case *PairNode:
+		return []Node{n.Key, n.Value}
+	case *SpreadNode:
+		return []Node{n.Node}
+	}
+	return nil
+}
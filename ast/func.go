@@ -9,4 +9,8 @@ type Function struct {
 	Types     []reflect.Type
 	Validate  func(args []reflect.Type) (reflect.Type, error)
 	Predicate bool
+	Pure      bool // result depends only on arguments, may be evaluated at compile time
+	Context   bool // Func receives context.Context of evaluation as first argument
+	// HTTPTimeout makes Func receive conf.Config.HTTPTimeout after the context.
+	HTTPTimeout bool
 }
@@ -13,18 +13,31 @@ type Node interface {
 	SetLocation(file.Location)
 	Type() reflect.Type
 	SetType(reflect.Type)
+	// Comment returns user annotation of node, printed as /* comment */.
+	Comment() string
+	SetComment(string)
 	String() string
 }
 
 func Patch(node *Node, newNode Node) {
 	newNode.SetType((*node).Type())
 	newNode.SetLocation((*node).Location())
+	KeepComment(*node, newNode)
 	*node = newNode
 }
 
+// KeepComment copies comment of old node to its replacement, unless
+// replacement has own comment.
+func KeepComment(old, replacement Node) {
+	if replacement.Comment() == "" {
+		replacement.SetComment(old.Comment())
+	}
+}
+
 type base struct {
 	loc      file.Location
 	nodeType reflect.Type
+	comment  string
 }
 
 func (n *base) Location() file.Location {
@@ -43,6 +56,14 @@ func (n *base) SetType(t reflect.Type) {
 	n.nodeType = t
 }
 
+func (n *base) Comment() string {
+	return n.comment
+}
+
+func (n *base) SetComment(comment string) {
+	n.comment = comment
+}
+
 type NilNode struct {
 	base
 }
@@ -51,8 +72,9 @@ type IdentifierNode struct {
 	base
 	Value       string
 	FieldIndex  []int
-	Method      bool // true if method, false if field
-	MethodIndex int  // index of method, set only if Method is true
+	Method      bool      // true if method, false if field
+	MethodIndex int       // index of method, set only if Method is true
+	Func        *Function // set if identifier refers to a builtin or function
 }
 
 type IntegerNode struct {
@@ -77,7 +99,8 @@ type StringNode struct {
 
 type ConstantNode struct {
 	base
-	Value any
+	Value        any
+	OriginalExpr string // expression folded into constant by optimizer
 }
 
 type UnaryNode struct {
@@ -134,6 +157,17 @@ type BuiltinNode struct {
 	Arguments []Node
 	Throws    bool
 	Map       Node
+	Pure      bool // call has no side effects and may be evaluated at compile time
+}
+
+// HasSpread reports whether any argument is a SpreadNode.
+func (n *BuiltinNode) HasSpread() bool {
+	for _, arg := range n.Arguments {
+		if _, ok := arg.(*SpreadNode); ok {
+			return true
+		}
+	}
+	return false
 }
 
 type ClosureNode struct {
@@ -146,6 +180,13 @@ type PointerNode struct {
 	Name string
 }
 
+type FunctionNode struct {
+	base
+	Name   string // Name the function is bound to, used for recursion.
+	Params []string
+	Body   Node
+}
+
 type ConditionalNode struct {
 	base
 	Cond Node
@@ -160,6 +201,15 @@ type VariableDeclaratorNode struct {
 	Expr  Node
 }
 
+// BlockNode is `do { let x = f(); g(x); x + 1 }`. Statements are let
+// declarations (VariableDeclaratorNode without Expr) or expressions
+// evaluated for their side effects. Result is the value of the block.
+type BlockNode struct {
+	base
+	Statements []Node
+	Result     Node
+}
+
 type ArrayNode struct {
 	base
 	Nodes []Node
@@ -175,3 +225,10 @@ type PairNode struct {
 	Key   Node
 	Value Node
 }
+
+// SpreadNode is `...array` argument of a builtin, which passes elements
+// of array as separate arguments.
+type SpreadNode struct {
+	base
+	Node Node
+}
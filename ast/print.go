@@ -10,38 +10,41 @@ import (
 )
 
 func (n *NilNode) String() string {
-	return "nil"
+	return withComment(n, "nil")
 }
 
 func (n *IdentifierNode) String() string {
-	return n.Value
+	return withComment(n, n.Value)
 }
 
 func (n *IntegerNode) String() string {
-	return fmt.Sprintf("%d", n.Value)
+	return withComment(n, fmt.Sprintf("%d", n.Value))
 }
 
 func (n *FloatNode) String() string {
-	return fmt.Sprintf("%v", n.Value)
+	return withComment(n, fmt.Sprintf("%v", n.Value))
 }
 
 func (n *BoolNode) String() string {
-	return fmt.Sprintf("%t", n.Value)
+	return withComment(n, fmt.Sprintf("%t", n.Value))
 }
 
 func (n *StringNode) String() string {
-	return fmt.Sprintf("%q", n.Value)
+	return withComment(n, fmt.Sprintf("%q", n.Value))
 }
 
 func (n *ConstantNode) String() string {
 	if n.Value == nil {
-		return "nil"
+		return withComment(n, "nil")
 	}
 	b, err := json.Marshal(n.Value)
 	if err != nil {
 		panic(err)
 	}
-	return string(b)
+	if n.OriginalExpr != "" {
+		return withComment(n, fmt.Sprintf("/* %s */ %s", strings.ReplaceAll(n.OriginalExpr, "*/", "* /"), b))
+	}
+	return withComment(n, string(b))
 }
 
 func (n *UnaryNode) String() string {
@@ -52,58 +55,80 @@ func (n *UnaryNode) String() string {
 		op = fmt.Sprintf("%s", n.Operator)
 	}
 	if _, ok := n.Node.(*BinaryNode); ok {
-		return fmt.Sprintf("%s(%s)", op, n.Node.String())
+		return withComment(n, fmt.Sprintf("%s(%s)", op, n.Node.String()))
 	}
-	return fmt.Sprintf("%s%s", op, n.Node.String())
+	return withComment(n, fmt.Sprintf("%s%s", op, n.Node.String()))
 }
 
 func (n *BinaryNode) String() string {
-	var left, right string
-	if b, ok := n.Left.(*BinaryNode); ok && operator.Less(b.Operator, n.Operator) {
-		left = fmt.Sprintf("(%s)", n.Left.String())
-	} else {
-		left = n.Left.String()
+	op := operator.Binary[n.Operator]
+	left := n.Left.String()
+	if needsParens(n.Left, op, operator.Right) {
+		left = fmt.Sprintf("(%s)", left)
 	}
-	if b, ok := n.Right.(*BinaryNode); ok && operator.Less(b.Operator, n.Operator) {
-		right = fmt.Sprintf("(%s)", n.Right.String())
-	} else {
-		right = n.Right.String()
+	right := n.Right.String()
+	if needsParens(n.Right, op, operator.Left) {
+		right = fmt.Sprintf("(%s)", right)
+	}
+	return withComment(n, fmt.Sprintf("%s %s %s", left, n.Operator, right))
+}
+
+// needsParens reports whether operand of binary operator op must be
+// wrapped in parentheses to be parsed back into the same tree. Operand of
+// the same precedence is wrapped on the side where op does not group, it
+// is the left side for assoc equal to Right and vice versa.
+func needsParens(operand Node, op operator.Operator, assoc operator.Associativity) bool {
+	switch o := operand.(type) {
+	case *BinaryNode:
+		p := operator.Binary[o.Operator].Precedence
+		return p < op.Precedence || p == op.Precedence && op.Associativity == assoc
+	case *UnaryNode:
+		return operator.Unary[o.Operator].Precedence < op.Precedence
+	case *ConditionalNode, *VariableDeclaratorNode:
+		return true
 	}
-	return fmt.Sprintf("%s %s %s", left, n.Operator, right)
+	return false
 }
 
 func (n *ChainNode) String() string {
-	return n.Node.String()
+	return withComment(n, n.Node.String())
 }
 
 func (n *MemberNode) String() string {
+	operand := postfixOperand(n.Node)
 	if n.Optional {
 		if str, ok := n.Property.(*StringNode); ok && utils.IsValidIdentifier(str.Value) {
-			return fmt.Sprintf("%s?.%s", n.Node.String(), str.Value)
+			return withComment(n, fmt.Sprintf("%s?.%s", operand, str.Value))
 		} else {
-			return fmt.Sprintf("get(%s, %s)", n.Node.String(), n.Property.String())
+			return withComment(n, fmt.Sprintf("%s?.[%s]", operand, n.Property.String()))
 		}
 	}
 	if str, ok := n.Property.(*StringNode); ok && utils.IsValidIdentifier(str.Value) {
-		if _, ok := n.Node.(*PointerNode); ok {
-			return fmt.Sprintf(".%s", str.Value)
+		if p, ok := n.Node.(*PointerNode); ok && p.Name == "" {
+			return withComment(n, fmt.Sprintf(".%s", str.Value))
 		}
-		return fmt.Sprintf("%s.%s", n.Node.String(), str.Value)
+		return withComment(n, fmt.Sprintf("%s.%s", operand, str.Value))
 	}
-	return fmt.Sprintf("%s[%s]", n.Node.String(), n.Property.String())
+	if i, ok := n.Property.(*IntegerNode); ok && i.Value >= 0 {
+		if p, ok := n.Node.(*PointerNode); ok && p.Name == "" {
+			return withComment(n, fmt.Sprintf("#%d", i.Value))
+		}
+	}
+	return withComment(n, fmt.Sprintf("%s[%s]", operand, n.Property.String()))
 }
 
 func (n *SliceNode) String() string {
+	operand := postfixOperand(n.Node)
 	if n.From == nil && n.To == nil {
-		return fmt.Sprintf("%s[:]", n.Node.String())
+		return withComment(n, fmt.Sprintf("%s[:]", operand))
 	}
 	if n.From == nil {
-		return fmt.Sprintf("%s[:%s]", n.Node.String(), n.To.String())
+		return withComment(n, fmt.Sprintf("%s[:%s]", operand, n.To.String()))
 	}
 	if n.To == nil {
-		return fmt.Sprintf("%s[%s:]", n.Node.String(), n.From.String())
+		return withComment(n, fmt.Sprintf("%s[%s:]", operand, n.From.String()))
 	}
-	return fmt.Sprintf("%s[%s:%s]", n.Node.String(), n.From.String(), n.To.String())
+	return withComment(n, fmt.Sprintf("%s[%s:%s]", operand, n.From.String(), n.To.String()))
 }
 
 func (n *CallNode) String() string {
@@ -111,7 +136,7 @@ func (n *CallNode) String() string {
 	for i, arg := range n.Arguments {
 		arguments[i] = arg.String()
 	}
-	return fmt.Sprintf("%s(%s)", n.Callee.String(), strings.Join(arguments, ", "))
+	return withComment(n, fmt.Sprintf("%s(%s)", postfixOperand(n.Callee), strings.Join(arguments, ", ")))
 }
 
 func (n *BuiltinNode) String() string {
@@ -119,19 +144,39 @@ func (n *BuiltinNode) String() string {
 	for i, arg := range n.Arguments {
 		arguments[i] = arg.String()
 	}
-	return fmt.Sprintf("%s(%s)", n.Name, strings.Join(arguments, ", "))
+	return withComment(n, fmt.Sprintf("%s(%s)", n.Name, strings.Join(arguments, ", ")))
 }
 
 func (n *ClosureNode) String() string {
-	return n.Node.String()
+	return withComment(n, n.Node.String())
 }
 
 func (n *PointerNode) String() string {
-	return "#"
+	return withComment(n, "#"+n.Name)
 }
 
 func (n *VariableDeclaratorNode) String() string {
-	return fmt.Sprintf("let %s = %s; %s", n.Name, n.Value.String(), n.Expr.String())
+	if n.Expr == nil { // statement of block
+		return withComment(n, fmt.Sprintf("let %s = %s", n.Name, n.Value.String()))
+	}
+	return withComment(n, fmt.Sprintf("let %s = %s; %s", n.Name, n.Value.String(), n.Expr.String()))
+}
+
+func (n *BlockNode) String() string {
+	parts := make([]string, 0, len(n.Statements)+1)
+	for _, stmt := range n.Statements {
+		if let, ok := stmt.(*VariableDeclaratorNode); ok && let.Expr == nil {
+			parts = append(parts, fmt.Sprintf("let %s = %s", let.Name, let.Value.String()))
+		} else {
+			parts = append(parts, stmt.String())
+		}
+	}
+	parts = append(parts, n.Result.String())
+	return withComment(n, fmt.Sprintf("do { %s }", strings.Join(parts, "; ")))
+}
+
+func (n *FunctionNode) String() string {
+	return withComment(n, fmt.Sprintf("func(%s) { %s }", strings.Join(n.Params, ", "), n.Body.String()))
 }
 
 func (n *ConditionalNode) String() string {
@@ -151,7 +196,7 @@ func (n *ConditionalNode) String() string {
 	} else {
 		exp2 = n.Exp2.String()
 	}
-	return fmt.Sprintf("%s ? %s : %s", cond, exp1, exp2)
+	return withComment(n, fmt.Sprintf("%s ? %s : %s", cond, exp1, exp2))
 }
 
 func (n *ArrayNode) String() string {
@@ -159,7 +204,7 @@ func (n *ArrayNode) String() string {
 	for i, node := range n.Nodes {
 		nodes[i] = node.String()
 	}
-	return fmt.Sprintf("[%s]", strings.Join(nodes, ", "))
+	return withComment(n, fmt.Sprintf("[%s]", strings.Join(nodes, ", ")))
 }
 
 func (n *MapNode) String() string {
@@ -167,9 +212,31 @@ func (n *MapNode) String() string {
 	for i, pair := range n.Pairs {
 		pairs[i] = pair.String()
 	}
-	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+	return withComment(n, fmt.Sprintf("{%s}", strings.Join(pairs, ", ")))
 }
 
 func (n *PairNode) String() string {
-	return fmt.Sprintf("%s: %s", n.Key.String(), n.Value.String())
+	return withComment(n, fmt.Sprintf("%s: %s", n.Key.String(), n.Value.String()))
+}
+
+func (n *SpreadNode) String() string {
+	return withComment(n, fmt.Sprintf("...%s", n.Node.String()))
+}
+
+// postfixOperand prints node followed by member access, slice or call,
+// wrapping operators in parentheses, as postfix binds tighter.
+func postfixOperand(node Node) string {
+	switch node.(type) {
+	case *BinaryNode, *UnaryNode, *ConditionalNode, *VariableDeclaratorNode:
+		return fmt.Sprintf("(%s)", node.String())
+	}
+	return node.String()
+}
+
+// withComment appends comment of node to its printed form.
+func withComment(n Node, s string) string {
+	if c := n.Comment(); c != "" {
+		return fmt.Sprintf("%s /* %s */", s, strings.ReplaceAll(c, "*/", "* /"))
+	}
+	return s
 }
@@ -47,7 +47,16 @@ func Walk(node *Node, v Visitor) {
 	case *PointerNode:
 	case *VariableDeclaratorNode:
 		Walk(&n.Value, v)
-		Walk(&n.Expr, v)
+		if n.Expr != nil {
+			Walk(&n.Expr, v)
+		}
+	case *BlockNode:
+		for i := range n.Statements {
+			Walk(&n.Statements[i], v)
+		}
+		Walk(&n.Result, v)
+	case *FunctionNode:
+		Walk(&n.Body, v)
 	case *ConditionalNode:
 		Walk(&n.Cond, v)
 		Walk(&n.Exp1, v)
@@ -63,9 +72,70 @@ func Walk(node *Node, v Visitor) {
 	case *PairNode:
 		Walk(&n.Key, v)
 		Walk(&n.Value, v)
+	case *SpreadNode:
+		Walk(&n.Node, v)
 	default:
 		panic(fmt.Sprintf("undefined node type (%T)", node))
 	}
 
 	v.Visit(node)
 }
+
+type rewriter func(Node) (Node, bool)
+
+func (fn rewriter) Visit(node *Node) {
+	if replacement, ok := fn(*node); ok {
+		Patch(node, replacement)
+	}
+}
+
+// Rewrite calls fn for every node of tree, children before parents, and
+// patches nodes for which fn returns a replacement and true. Parents see
+// already rewritten children. Returns root of rewritten tree.
+func Rewrite(node Node, fn func(Node) (Node, bool)) Node {
+	Walk(&node, rewriter(fn))
+	return node
+}
+
+// Find returns all nodes of tree, parents before children, for which
+// pred returns true.
+func Find(node Node, pred func(Node) bool) []Node {
+	var found []Node
+	find(node, func(n Node) bool {
+		if pred(n) {
+			found = append(found, n)
+		}
+		return false
+	})
+	return found
+}
+
+// FindFirst returns the first node found by Find, or nil. Traversal
+// stops at the match.
+func FindFirst(node Node, pred func(Node) bool) Node {
+	var found Node
+	find(node, func(n Node) bool {
+		if pred(n) {
+			found = n
+			return true
+		}
+		return false
+	})
+	return found
+}
+
+// find visits nodes in pre-order until fn returns true.
+func find(node Node, fn func(Node) bool) bool {
+	if node == nil {
+		return false
+	}
+	if fn(node) {
+		return true
+	}
+	for _, child := range Children(node) {
+		if find(child, fn) {
+			return true
+		}
+	}
+	return false
+}
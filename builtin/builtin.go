@@ -1,11 +1,16 @@
 package builtin
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"html"
+	"net"
+	"os"
 	"reflect"
-	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -113,6 +118,26 @@ var Builtins = []*ast.Function{
 		Fast:  Type,
 		Types: types(new(func(any) string)),
 	},
+	{
+		Name: "conformsTo",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("invalid number of arguments (expected 2, got %d)", len(args))
+			}
+			return ConformsTo(args[0], args[1])
+		},
+		Validate: func(args []reflect.Type) (reflect.Type, error) {
+			if len(args) != 2 {
+				return anyType, fmt.Errorf("invalid number of arguments (expected 2, got %d)", len(args))
+			}
+			switch kind(args[1]) {
+			case reflect.Interface, reflect.Map:
+				return boolType, nil
+			}
+			return anyType, fmt.Errorf("invalid schema for conformsTo (expected map, got %s)", args[1])
+		},
+	},
 	{
 		Name: "abs",
 		Fast: Abs,
@@ -214,6 +239,7 @@ var Builtins = []*ast.Function{
 	},
 	{
 		Name: "upper",
+		Pure: true,
 		Fast: func(arg any) any {
 			return strings.ToUpper(arg.(string))
 		},
@@ -221,11 +247,119 @@ var Builtins = []*ast.Function{
 	},
 	{
 		Name: "lower",
+		Pure: true,
 		Fast: func(arg any) any {
 			return strings.ToLower(arg.(string))
 		},
 		Types: types(strings.ToLower),
 	},
+	{
+		Name: "truncate",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("invalid number of arguments (expected 2-3, got %d)", len(args))
+			}
+			suffix := "…"
+			if len(args) == 3 {
+				suffix = args[2].(string)
+			}
+			return Truncate(args[0].(string), runtime.ToInt(args[1]), suffix), nil
+		},
+		Types: types(
+			new(func(string, int) string),
+			new(func(string, int, string) string),
+		),
+	},
+	{
+		Name: "camelCase",
+		Pure: true,
+		Fast: func(arg any) any {
+			return CamelCase(arg.(string))
+		},
+		Types: types(CamelCase),
+	},
+	{
+		Name: "snakeCase",
+		Pure: true,
+		Fast: func(arg any) any {
+			return SnakeCase(arg.(string))
+		},
+		Types: types(SnakeCase),
+	},
+	{
+		Name: "kebabCase",
+		Pure: true,
+		Fast: func(arg any) any {
+			return KebabCase(arg.(string))
+		},
+		Types: types(KebabCase),
+	},
+	{
+		Name: "titleCase",
+		Pure: true,
+		Fast: func(arg any) any {
+			return TitleCase(arg.(string))
+		},
+		Types: types(TitleCase),
+	},
+	{
+		Name: "xmlEscape",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			var b strings.Builder
+			if err := xml.EscapeText(&b, []byte(args[0].(string))); err != nil {
+				return nil, err
+			}
+			return b.String(), nil
+		},
+		Types: types(new(func(string) string)),
+	},
+	{
+		Name: "xmlUnescape",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			var s string
+			d := xml.NewDecoder(strings.NewReader("<x>" + args[0].(string) + "</x>"))
+			if err := d.Decode(&s); err != nil {
+				return nil, fmt.Errorf("invalid xml text: %w", err)
+			}
+			return s, nil
+		},
+		Types: types(new(func(string) string)),
+	},
+	{
+		Name: "htmlEscape",
+		Pure: true,
+		Fast: func(arg any) any {
+			return html.EscapeString(arg.(string))
+		},
+		Types: types(html.EscapeString),
+	},
+	{
+		Name: "htmlUnescape",
+		Pure: true,
+		Fast: func(arg any) any {
+			return html.UnescapeString(arg.(string))
+		},
+		Types: types(html.UnescapeString),
+	},
+	{
+		Name: "emailValidate",
+		Pure: true,
+		Fast: func(arg any) any {
+			return EmailValidate(arg.(string))
+		},
+		Types: types(EmailValidate),
+	},
+	{
+		Name: "phoneFormat",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			return PhoneFormat(args[0].(string), args[1].(string))
+		},
+		Types: types(new(func(string, string) string)),
+	},
 	{
 		Name: "split",
 		Func: func(args ...any) (any, error) {
@@ -340,6 +474,179 @@ var Builtins = []*ast.Function{
 		},
 		Types: types(strings.HasSuffix),
 	},
+	{
+		Name: "ipInCidr",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			_, network, err := net.ParseCIDR(args[1].(string))
+			if err != nil {
+				return nil, err
+			}
+			return network.Contains(net.ParseIP(args[0].(string))), nil
+		},
+		Types: types(new(func(string, string) bool)),
+	},
+	{
+		Name: "ipVersion",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			ip, err := parseIP(args[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			if ip.To4() != nil {
+				return 4, nil
+			}
+			return 6, nil
+		},
+		Types: types(new(func(string) int)),
+	},
+	{
+		Name: "ipToInt",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			ip, err := parseIP(args[0].(string))
+			if err != nil {
+				return nil, err
+			}
+			if v4 := ip.To4(); v4 != nil {
+				return int64(binary.BigEndian.Uint32(v4)), nil
+			}
+			// IPv6 addresses do not fit into int64, only the lower 64 bits are returned.
+			return int64(binary.BigEndian.Uint64(ip[8:])), nil
+		},
+		Types: types(new(func(string) int64)),
+	},
+	{
+		Name: "intToIP",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			n := runtime.ToInt64(args[0])
+			version := 4
+			if len(args) == 2 {
+				version = runtime.ToInt(args[1])
+			}
+			switch version {
+			case 4:
+				ip := make(net.IP, net.IPv4len)
+				binary.BigEndian.PutUint32(ip, uint32(n))
+				return ip.String(), nil
+			case 6:
+				ip := make(net.IP, net.IPv6len)
+				binary.BigEndian.PutUint64(ip[8:], uint64(n))
+				return ip.String(), nil
+			}
+			return nil, fmt.Errorf("invalid ip version %d", version)
+		},
+		Types: types(new(func(int64) string), new(func(int64, int) string)),
+	},
+	{
+		Name: "jsonpath",
+		Func: func(args ...any) (any, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("invalid number of arguments (expected 2, got %d)", len(args))
+			}
+			path, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot use %T as jsonpath", args[1])
+			}
+			return JSONPath(args[0], path)
+		},
+		Types: types(new(func(any, string) []any)),
+	},
+	{
+		Name: "semverParse",
+		Pure: true,
+		Fast: func(arg any) any {
+			v := runtime.ParseSemVer(arg.(string), false)
+			return map[string]any{"major": v.Major, "minor": v.Minor, "patch": v.Patch}
+		},
+		Types: types(new(func(string) map[string]any)),
+	},
+	{
+		Name: "editDistance",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			return EditDistance(args[0].(string), args[1].(string)), nil
+		},
+		Types: types(new(func(string, string) int)),
+	},
+	{
+		Name: "fuzzyMatch",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			return EditDistance(args[0].(string), args[1].(string)) <= runtime.ToInt(args[2]), nil
+		},
+		Types: types(new(func(string, string, int) bool)),
+	},
+	{
+		Name: "formatNumber",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			if len(args) < 1 || len(args) > 4 {
+				return nil, fmt.Errorf("invalid number of arguments (expected 1-4, got %d)", len(args))
+			}
+			decimals, thousandsSep, decimalSep := 2, ",", "."
+			if len(args) > 1 {
+				decimals = runtime.ToInt(args[1])
+			}
+			if len(args) > 2 {
+				thousandsSep = args[2].(string)
+			}
+			if len(args) > 3 {
+				decimalSep = args[3].(string)
+			}
+			return FormatNumber(runtime.ToFloat64(args[0]), decimals, thousandsSep, decimalSep)
+		},
+		Validate: func(args []reflect.Type) (reflect.Type, error) {
+			if len(args) < 1 || len(args) > 4 {
+				return anyType, fmt.Errorf("invalid number of arguments (expected 1-4, got %d)", len(args))
+			}
+			switch kind(args[0]) {
+			case reflect.Interface, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			default:
+				return anyType, fmt.Errorf("cannot format %s", args[0])
+			}
+			if len(args) > 1 {
+				switch kind(args[1]) {
+				case reflect.Interface, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				default:
+					return anyType, fmt.Errorf("invalid number of decimals %s", args[1])
+				}
+			}
+			for _, arg := range args[min(len(args), 2):] {
+				if kind(arg) != reflect.Interface && kind(arg) != reflect.String {
+					return anyType, fmt.Errorf("invalid separator %s", arg)
+				}
+			}
+			return stringType, nil
+		},
+	},
+	{
+		Name: "formatCurrency",
+		Pure: true,
+		Func: func(args ...any) (any, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("invalid number of arguments (expected 2-3, got %d)", len(args))
+			}
+			decimals := 2
+			if len(args) == 3 {
+				decimals = runtime.ToInt(args[2])
+			}
+			s, err := FormatNumber(runtime.ToFloat64(args[0]), decimals, ",", ".")
+			if err != nil {
+				return nil, err
+			}
+			if rest, ok := strings.CutPrefix(s, "-"); ok {
+				return "-" + args[1].(string) + rest, nil
+			}
+			return args[1].(string) + s, nil
+		},
+		Types: types(
+			new(func(float64, string) string),
+			new(func(float64, string, int) string),
+		),
+	},
 	{
 		Name: "max",
 		Func: Max,
@@ -550,47 +857,67 @@ var Builtins = []*ast.Function{
 		Types: types(new(func(string) string)),
 	},
 	{
-		Name: "now",
+		// Disabled by default as it exposes process environment,
+		// enable with conf.Config.Allow("env").
+		Name: "env",
 		Func: func(args ...any) (any, error) {
-			layouts := []string{
-				"2006-01-02",
-				"15:04:05",
-				"2006-01-02 15:04:05",
-				time.RFC3339,
-				time.RFC822,
-				time.RFC850,
-				time.RFC1123,
-			}
-			if len(args) > 0 {
-				layout := args[0].(string)
-				if slices.Contains(layouts, layout) {
-					return time.Now().Format(layout), nil
-				}
+			return os.Getenv(args[0].(string)), nil
+		},
+		Types: types(os.Getenv),
+	},
+	{
+		// Disabled by default, enable with conf.Config.Allow("timestamp").
+		Name: "timestamp",
+		Func: func(args ...any) (any, error) {
+			return time.Now().UnixMilli(), nil
+		},
+		Types: types(new(func() int64)),
+	},
+	{
+		// Disabled by default, enable with conf.Config.Allow("sleep").
+		Name:    "sleep",
+		Context: true,
+		Func: func(args ...any) (any, error) {
+			ctx := args[0].(context.Context)
+			timer := time.NewTimer(time.Duration(runtime.ToInt64(args[1])) * time.Millisecond)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				return nil, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
 			}
-			return time.Now(), nil
 		},
-		Types: types(new(func() time.Time)),
+		Types: types(new(func(int) any)),
 	},
 	{
-		Name: "now_utc",
+		// Disabled by default, enable with conf.Config.Allow("http").
+		Name:        "http",
+		Context:     true,
+		HTTPTimeout: true,
 		Func: func(args ...any) (any, error) {
-			layouts := []string{
-				"2006-01-02",
-				"15:04:05",
-				"2006-01-02 15:04:05",
-				time.RFC3339,
-				time.RFC822,
-				time.RFC850,
-				time.RFC1123,
+			if len(args) < 3 || len(args) > 4 {
+				return nil, fmt.Errorf("invalid number of arguments (expected 1-2, got %d)", len(args)-2)
 			}
-			if len(args) > 0 {
-				layout := args[0].(string)
-				if slices.Contains(layouts, layout) {
-					return time.Now().UTC().Format(layout), nil
-				}
+			var options map[string]any
+			if len(args) == 4 {
+				options, _ = args[3].(map[string]any)
 			}
-			return time.Now().UTC(), nil
+			return HTTP(args[0].(context.Context), args[1].(time.Duration), args[2].(string), options)
 		},
+		Types: types(
+			new(func(string) map[string]any),
+			new(func(string, map[string]any) map[string]any),
+		),
+	},
+	{
+		Name:  "now",
+		Func:  nowIn(nil),
+		Types: types(new(func() time.Time)),
+	},
+	{
+		Name:  "now_utc",
+		Func:  nowIn(time.UTC),
 		Types: types(new(func() time.Time)),
 	},
 	{
@@ -602,43 +929,7 @@ var Builtins = []*ast.Function{
 	},
 	{
 		Name: "date",
-		Func: func(args ...any) (any, error) {
-			date := args[0].(string)
-			if len(args) == 2 {
-				layout := args[1].(string)
-				return time.Parse(layout, date)
-			}
-			if len(args) == 3 {
-				layout := args[1].(string)
-				timeZone := args[2].(string)
-				tz, err := time.LoadLocation(timeZone)
-				if err != nil {
-					return nil, err
-				}
-				t, err := time.ParseInLocation(layout, date, tz)
-				if err != nil {
-					return nil, err
-				}
-				return t, nil
-			}
-
-			layouts := []string{
-				"2006-01-02",
-				"15:04:05",
-				"2006-01-02 15:04:05",
-				time.RFC3339,
-				time.RFC822,
-				time.RFC850,
-				time.RFC1123,
-			}
-			for _, layout := range layouts {
-				t, err := time.Parse(layout, date)
-				if err == nil {
-					return t, nil
-				}
-			}
-			return nil, fmt.Errorf("invalid date %s", date)
-		},
+		Func: dateIn(nil),
 		Types: types(
 			new(func(string) time.Time),
 			new(func(string, string) time.Time),
@@ -744,6 +1035,13 @@ var Builtins = []*ast.Function{
 			if len(args) != 1 {
 				return nil, fmt.Errorf("invalid number of arguments (expected 1, got %d)", len(args))
 			}
+			if m, ok := args[0].(*runtime.OrderedMap); ok {
+				out := make([]any, m.Len())
+				for i, key := range m.Keys() {
+					out[i] = key
+				}
+				return out, nil
+			}
 			v := reflect.ValueOf(args[0])
 			if v.Kind() != reflect.Map {
 				return nil, fmt.Errorf("cannot get keys from %s", v.Kind())
@@ -774,6 +1072,9 @@ var Builtins = []*ast.Function{
 			if len(args) != 1 {
 				return nil, fmt.Errorf("invalid number of arguments (expected 1, got %d)", len(args))
 			}
+			if m, ok := args[0].(*runtime.OrderedMap); ok {
+				return m.Values(), nil
+			}
 			v := reflect.ValueOf(args[0])
 			if v.Kind() != reflect.Map {
 				return nil, fmt.Errorf("cannot get values from %s", v.Kind())
@@ -804,6 +1105,14 @@ var Builtins = []*ast.Function{
 			if len(args) != 1 {
 				return nil, fmt.Errorf("invalid number of arguments (expected 1, got %d)", len(args))
 			}
+			if m, ok := args[0].(*runtime.OrderedMap); ok {
+				out := make([][2]any, m.Len())
+				for i, key := range m.Keys() {
+					value, _ := m.Get(key)
+					out[i] = [2]any{key, value}
+				}
+				return out, nil
+			}
 			v := reflect.ValueOf(args[0])
 			if v.Kind() != reflect.Map {
 				return nil, fmt.Errorf("cannot transform %s to pairs", v.Kind())
@@ -0,0 +1,35 @@
+package builtin
+
+// Categories groups builtins by their purpose.
+var Categories = map[string][]string{
+	"collection": {
+		"all", "none", "any", "one", "filter", "map", "find", "findIndex", "findLast", "findLastIndex",
+		"count", "groupBy", "reduce", "len", "first", "last", "get", "take", "keys", "values",
+		"toPairs", "fromPairs", "sort", "sortBy",
+	},
+	"math": {
+		"abs", "int", "float", "max", "min", "sum", "mean", "median",
+	},
+	"string": {
+		"string", "trim", "trimPrefix", "trimSuffix", "upper", "lower", "truncate",
+		"camelCase", "snakeCase", "kebabCase", "titleCase", "split", "splitAfter", "replace",
+		"repeat", "join", "indexOf", "lastIndexOf", "hasPrefix", "hasSuffix", "editDistance",
+		"fuzzyMatch", "formatNumber", "formatCurrency", "concat", "emailValidate", "phoneFormat",
+	},
+	"encoding": {
+		"xmlEscape", "xmlUnescape", "htmlEscape", "htmlUnescape", "toJSON", "fromJSON",
+		"toBase64", "fromBase64", "jsonpath",
+	},
+	"date": {
+		"now", "now_utc", "duration", "date", "timestamp",
+	},
+	"network": {
+		"ipInCidr", "ipVersion", "ipToInt", "intToIP", "http",
+	},
+	"version": {
+		"semverParse",
+	},
+	"system": {
+		"type", "conformsTo", "env", "sleep",
+	},
+}
@@ -0,0 +1,59 @@
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// emailRegexp is the pragmatic pattern used by HTML e-mail inputs,
+// it does not implement full RFC 5322 grammar (comments, quoted local parts).
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// EmailValidate reports whether s looks like an e-mail address.
+func EmailValidate(s string) bool {
+	return emailRegexp.MatchString(s)
+}
+
+// callingCodes maps ISO 3166 country codes to telephone calling codes.
+var callingCodes = map[string]string{
+	"US": "1", "CA": "1", "GB": "44", "DE": "49", "FR": "33", "ES": "34",
+	"IT": "39", "NL": "31", "BE": "32", "CH": "41", "AT": "43", "SE": "46",
+	"NO": "47", "DK": "45", "FI": "358", "PL": "48", "PT": "351", "IE": "353",
+	"RU": "7", "UA": "380", "TR": "90", "IN": "91", "CN": "86", "JP": "81",
+	"KR": "82", "SG": "65", "AU": "61", "NZ": "64", "BR": "55", "MX": "52",
+	"AR": "54", "ZA": "27", "IL": "972", "AE": "971",
+}
+
+// PhoneFormat normalizes phone number to E.164 format. Numbers without
+// international prefix ("+" or "00") are treated as national numbers of
+// the country, a leading trunk prefix "0" is dropped. Only the length of
+// the result is validated, not numbering plans.
+func PhoneFormat(s, country string) (string, error) {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	number := digits.String()
+	trimmed := strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(trimmed, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		code, ok := callingCodes[strings.ToUpper(country)]
+		if !ok {
+			return "", fmt.Errorf("unknown country %q", country)
+		}
+		if code == "1" && len(number) == 11 && strings.HasPrefix(number, "1") {
+			number = number[1:]
+		}
+		number = code + strings.TrimPrefix(number, "0")
+	}
+	if len(number) < 8 || len(number) > 15 {
+		return "", fmt.Errorf("invalid phone number %q", s)
+	}
+	return "+" + number, nil
+}
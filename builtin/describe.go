@@ -0,0 +1,133 @@
+package builtin
+
+// BuiltinDef describes builtin function for documentation and editor
+// integrations.
+type BuiltinDef struct {
+	Name        string
+	Signature   string
+	Description string
+	Examples    string
+	Pure        bool // result depends only on arguments and may be folded at compile time
+}
+
+// Describe returns definition of builtin by name.
+func Describe(name string) (BuiltinDef, bool) {
+	i, ok := Index[name]
+	if !ok {
+		return BuiltinDef{}, false
+	}
+	return describe(i), true
+}
+
+// AllBuiltins returns definitions of all builtins.
+func AllBuiltins() []BuiltinDef {
+	defs := make([]BuiltinDef, len(Builtins))
+	for i := range Builtins {
+		defs[i] = describe(i)
+	}
+	return defs
+}
+
+func describe(i int) BuiltinDef {
+	fn := Builtins[i]
+	d := docs[fn.Name]
+	return BuiltinDef{
+		Name:        fn.Name,
+		Signature:   d.signature,
+		Description: d.description,
+		Examples:    d.examples,
+		Pure:        fn.Pure,
+	}
+}
+
+type doc struct {
+	signature   string
+	description string
+	examples    string
+}
+
+var docs = map[string]doc{
+	"all":            {"all(array, predicate) bool", "Reports whether predicate is true for all elements.", `all(tweets, .Size < 280)`},
+	"none":           {"none(array, predicate) bool", "Reports whether predicate is false for all elements.", `none(tweets, .Size > 280)`},
+	"any":            {"any(array, predicate) bool", "Reports whether predicate is true for any element.", `any(tweets, .Size > 280)`},
+	"one":            {"one(array, predicate) bool", "Reports whether predicate is true for exactly one element.", `one(participants, .Winner)`},
+	"filter":         {"filter(array, predicate) array", "Returns elements for which predicate is true.", `filter(users, .Age >= 18)`},
+	"map":            {"map(array, predicate) array", "Returns results of predicate applied to every element.", `map(users, .Name)`},
+	"find":           {"find(array, predicate[, default]) any", "Returns first element for which predicate is true, or default (nil if omitted).", `find(users, .Name == "Bob")`},
+	"findIndex":      {"findIndex(array, predicate) int", "Returns index of first element for which predicate is true, or -1.", `findIndex([1, 2, 3], # > 1)`},
+	"findLast":       {"findLast(array, predicate[, default]) any", "Returns last element for which predicate is true, or default (nil if omitted).", `findLast([1, 2, 3], # > 1)`},
+	"findLastIndex":  {"findLastIndex(array, predicate) int", "Returns index of last element for which predicate is true, or -1.", `findLastIndex([1, 2, 3], # > 1)`},
+	"count":          {"count(array, predicate) int", "Returns number of elements for which predicate is true.", `count(users, .Active)`},
+	"groupBy":        {"groupBy(array, predicate) map", "Groups elements by result of predicate.", `groupBy(users, .Country)`},
+	"reduce":         {"reduce(array, predicate[, initial]) any", "Folds elements into accumulator #acc.", `reduce(1..5, #acc + #, 0)`},
+	"len":            {"len(v) int", "Returns length of array, map or string.", `len("hello")`},
+	"type":           {"type(v) string", "Returns name of type of v.", `type(42)`},
+	"conformsTo":     {"conformsTo(v, schema) bool", "Reports whether v has all fields of schema with types named as by type().", `conformsTo(user, {name: "string", age: "int"})`},
+	"abs":            {"abs(n) number", "Returns absolute value of n.", `abs(-5)`},
+	"int":            {"int(v) int", "Converts number or string to int.", `int("42")`},
+	"float":          {"float(v) float", "Converts number or string to float.", `float("1.5")`},
+	"string":         {"string(v) string", "Converts v to string.", `string(42)`},
+	"trim":           {"trim(s[, chars]) string", "Removes leading and trailing whitespace or chars.", `trim("  hi  ")`},
+	"trimPrefix":     {"trimPrefix(s[, prefix]) string", "Removes prefix, whitespace by default.", `trimPrefix("v1.0", "v")`},
+	"trimSuffix":     {"trimSuffix(s[, suffix]) string", "Removes suffix, whitespace by default.", `trimSuffix("file.go", ".go")`},
+	"upper":          {"upper(s) string", "Converts s to upper case.", `upper("hi")`},
+	"lower":          {"lower(s) string", "Converts s to lower case.", `lower("HI")`},
+	"truncate":       {"truncate(s, length[, suffix]) string", "Shortens s to at most length characters including suffix, \"…\" by default.", `truncate("hello world", 8)`},
+	"camelCase":      {"camelCase(s) string", "Converts s to camelCase.", `camelCase("user_name")`},
+	"snakeCase":      {"snakeCase(s) string", "Converts s to snake_case.", `snakeCase("userName")`},
+	"kebabCase":      {"kebabCase(s) string", "Converts s to kebab-case.", `kebabCase("userName")`},
+	"titleCase":      {"titleCase(s) string", "Capitalizes every word of s.", `titleCase("hello world")`},
+	"xmlEscape":      {"xmlEscape(s) string", "Escapes s for use in XML text.", `xmlEscape("a < b")`},
+	"xmlUnescape":    {"xmlUnescape(s) string", "Decodes XML entities of s.", `xmlUnescape("a &lt; b")`},
+	"htmlEscape":     {"htmlEscape(s) string", "Escapes s for use in HTML.", `htmlEscape("<b>")`},
+	"htmlUnescape":   {"htmlUnescape(s) string", "Decodes HTML entities of s.", `htmlUnescape("&lt;b&gt;")`},
+	"emailValidate":  {"emailValidate(s) bool", "Reports whether s is a valid email address.", `emailValidate("bob@example.com")`},
+	"phoneFormat":    {"phoneFormat(s, country) string", "Formats phone number in E.164 format.", `phoneFormat("(555) 123-4567", "US")`},
+	"split":          {"split(s, sep[, n]) array", "Splits s around sep.", `split("a,b,c", ",")`},
+	"splitAfter":     {"splitAfter(s, sep[, n]) array", "Splits s after every sep.", `splitAfter("a,b,c", ",")`},
+	"replace":        {"replace(s, old, new[, n]) string", "Replaces occurrences of old with new.", `replace("aaa", "a", "b")`},
+	"repeat":         {"repeat(s, n) string", "Repeats s n times.", `repeat("ab", 3)`},
+	"join":           {"join(array[, sep]) string", "Joins elements of array with sep.", `join(["a", "b"], ",")`},
+	"indexOf":        {"indexOf(s, substr) int", "Returns index of first substr in s, or -1.", `indexOf("hello", "l")`},
+	"lastIndexOf":    {"lastIndexOf(s, substr) int", "Returns index of last substr in s, or -1.", `lastIndexOf("hello", "l")`},
+	"hasPrefix":      {"hasPrefix(s, prefix) bool", "Reports whether s starts with prefix.", `hasPrefix("golang", "go")`},
+	"hasSuffix":      {"hasSuffix(s, suffix) bool", "Reports whether s ends with suffix.", `hasSuffix("golang", "lang")`},
+	"ipInCidr":       {"ipInCidr(ip, cidr) bool", "Reports whether ip belongs to cidr network.", `ipInCidr("10.0.0.1", "10.0.0.0/8")`},
+	"ipVersion":      {"ipVersion(ip) int", "Returns 4 or 6, or error for invalid address.", `ipVersion("::1")`},
+	"ipToInt":        {"ipToInt(ip) int", "Converts IP address to integer.", `ipToInt("10.0.0.1")`},
+	"intToIP":        {"intToIP(n[, version]) string", "Converts integer to IP address.", `intToIP(167772161)`},
+	"jsonpath":       {"jsonpath(data, path) array", "Returns values of data matched by JSONPath.", `jsonpath(order, "$.items[?(@.price > 10)].name")`},
+	"semverParse":    {"semverParse(s) map", "Parses semantic version into major, minor and patch.", `semverParse("1.2.3").minor`},
+	"editDistance":   {"editDistance(a, b) int", "Returns Levenshtein distance between a and b.", `editDistance("kitten", "sitting")`},
+	"fuzzyMatch":     {"fuzzyMatch(a, b, maxDistance) bool", "Reports whether edit distance of a and b is at most maxDistance.", `fuzzyMatch("color", "colour", 1)`},
+	"formatNumber":   {"formatNumber(n[, decimals, thousands, decimal]) string", "Formats n with thousands separators.", `formatNumber(1234.5, 2)`},
+	"formatCurrency": {"formatCurrency(n, symbol[, decimals]) string", "Formats n as amount of money.", `formatCurrency(1234.5, "$")`},
+	"max":            {"max(n...) number", "Returns the greatest of numbers or elements of array.", `max(1, 2, 3)`},
+	"min":            {"min(n...) number", "Returns the least of numbers or elements of array.", `min(1, 2, 3)`},
+	"sum":            {"sum(array) number", "Returns sum of elements.", `sum([1, 2, 3])`},
+	"mean":           {"mean(n...) float", "Returns arithmetic mean of numbers.", `mean([1, 2, 3])`},
+	"median":         {"median(n...) float", "Returns median of numbers.", `median([1, 2, 3])`},
+	"toJSON":         {"toJSON(v) string", "Encodes v as JSON.", `toJSON({a: 1})`},
+	"fromJSON":       {"fromJSON(s) any", "Decodes JSON string.", `fromJSON("[1, 2]")`},
+	"toBase64":       {"toBase64(s) string", "Encodes s with base64.", `toBase64("hi")`},
+	"fromBase64":     {"fromBase64(s) string", "Decodes base64 string.", `fromBase64("aGk=")`},
+	"env":            {"env(name) string", "Returns value of environment variable. Disabled by default.", `env("HOME")`},
+	"timestamp":      {"timestamp() int", "Returns current Unix time in milliseconds. Disabled by default.", `timestamp()`},
+	"sleep":          {"sleep(ms)", "Pauses evaluation for ms milliseconds. Disabled by default.", `sleep(100)`},
+	"http":           {"http(url[, options]) map", "Makes HTTP request, returns status, body and headers. Disabled by default.", `http("https://example.com", {method: "POST", body: {a: 1}}).status`},
+	"now":            {"now() time", "Returns current local time.", `now().Year()`},
+	"now_utc":        {"now_utc() time", "Returns current time in UTC.", `now_utc().Hour()`},
+	"duration":       {"duration(s) duration", "Parses duration like \"1h30m\".", `duration("1h") > duration("30m")`},
+	"date":           {"date(s[, layout[, timezone]]) time", "Parses date, with common layouts by default.", `date("2024-01-02")`},
+	"first":          {"first(array) any", "Returns first element, or nil.", `first([1, 2, 3])`},
+	"last":           {"last(array) any", "Returns last element, or nil.", `last([1, 2, 3])`},
+	"get":            {"get(v, key) any", "Returns element of array or map by key, or nil if it does not exist.", `get(user, "name")`},
+	"take":           {"take(array, n) array", "Returns first n elements.", `take([1, 2, 3], 2)`},
+	"keys":           {"keys(map) array", "Returns keys of map.", `keys({a: 1})`},
+	"values":         {"values(map) array", "Returns values of map.", `values({a: 1})`},
+	"toPairs":        {"toPairs(map) array", "Returns [key, value] pairs of map.", `toPairs({a: 1})`},
+	"fromPairs":      {"fromPairs(array) map", "Builds map from [key, value] pairs.", `fromPairs([["a", 1]])`},
+	"sort":           {"sort(array[, order]) array", "Sorts elements, order is \"asc\" or \"desc\".", `sort([3, 1, 2], "desc")`},
+	"sortBy":         {"sortBy(array, field[, order]) array", "Sorts elements by field.", `sortBy(users, "Age")`},
+	"concat":         {"concat(v...) string", "Concatenates string forms of arguments.", `concat("a", 1)`},
+}
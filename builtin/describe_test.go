@@ -0,0 +1,20 @@
+package builtin_test
+
+import (
+	"testing"
+
+	"github.com/oarkflow/expr/builtin"
+)
+
+func TestDescribe(t *testing.T) {
+	for _, fn := range builtin.Builtins {
+		def, ok := builtin.Describe(fn.Name)
+		if !ok {
+			t.Errorf("%v is not described", fn.Name)
+			continue
+		}
+		if def.Signature == "" || def.Description == "" {
+			t.Errorf("%v has no doc", fn.Name)
+		}
+	}
+}
@@ -2,14 +2,21 @@ package builtin
 
 import (
 	"fmt"
+	"math"
+	"net"
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/oarkflow/expr/vm/runtime"
 )
 
 func Len(x any) any {
+	if m, ok := x.(*runtime.OrderedMap); ok {
+		return m.Len()
+	}
 	v := reflect.ValueOf(x)
 	switch v.Kind() {
 	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
@@ -61,6 +68,67 @@ func Type(arg any) any {
 	return "unknown"
 }
 
+// ConformsTo reports whether value has all fields of schema with types
+// named as by the type builtin. Schema maps field names to type names,
+// "any", or nested schemas.
+func ConformsTo(value, schema any) (bool, error) {
+	s := reflect.ValueOf(schema)
+	if s.Kind() != reflect.Map {
+		return false, fmt.Errorf("invalid schema for conformsTo (expected map, got %T)", schema)
+	}
+	for _, key := range s.MapKeys() {
+		name, ok := key.Interface().(string)
+		if !ok {
+			return false, fmt.Errorf("invalid field name %v in schema", key.Interface())
+		}
+		field, ok := fieldOf(value, name)
+		if !ok {
+			return false, nil
+		}
+		switch want := s.MapIndex(key).Interface().(type) {
+		case string:
+			if want != "any" && Type(field) != want {
+				return false, nil
+			}
+		default:
+			if ok, err := ConformsTo(field, want); !ok || err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// fieldOf returns value of map key or struct field by name.
+func fieldOf(value any, name string) (any, bool) {
+	if m, ok := value.(*runtime.OrderedMap); ok {
+		return m.Get(name)
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		field := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !field.IsValid() {
+			return nil, false
+		}
+		return field.Interface(), true
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && (runtime.FieldName(f, runtime.DefaultTagName) == name || f.Name == name) {
+				return v.Field(i).Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
+
 func Abs(x any) any {
 	switch x.(type) {
 	case float32:
@@ -245,3 +313,144 @@ func Concat(args ...any) (any, error) {
 	argsString := strings.Join(stringArgs, "")
 	return argsString, nil
 }
+
+// EditDistance returns the Levenshtein distance between a and b,
+// counted in runes.
+func EditDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address %q", s)
+	}
+	return ip, nil
+}
+
+// FormatNumber formats n with given number of decimals, grouping integer
+// digits by three with thousandsSep.
+func FormatNumber(n float64, decimals int, thousandsSep, decimalSep string) (string, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return "", fmt.Errorf("cannot format %v", n)
+	}
+	if decimals < 0 {
+		return "", fmt.Errorf("negative number of decimals %d", decimals)
+	}
+	s := strconv.FormatFloat(math.Abs(n), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	if n < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(thousandsSep)
+		}
+		b.WriteRune(d)
+	}
+	if fraction != "" {
+		b.WriteString(decimalSep)
+		b.WriteString(fraction)
+	}
+	return b.String(), nil
+}
+
+// Truncate shortens s to at most length runes, suffix included. The suffix
+// is appended only if s is truncated.
+func Truncate(s string, length int, suffix string) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	if length < 0 {
+		length = 0
+	}
+	tail := []rune(suffix)
+	if len(tail) >= length {
+		return string(tail[:length])
+	}
+	return string(runes[:length-len(tail)]) + suffix
+}
+
+// Words splits s into words on non-alphanumeric characters and on case
+// changes, e.g. "parseHTTPRequest_v2" into "parse", "HTTP", "Request", "v2".
+func Words(s string) []string {
+	var words []string
+	runes := []rune(s)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || next {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + strings.ToLower(s[size:])
+}
+
+func CamelCase(s string) string {
+	words := Words(s)
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(w)
+		} else {
+			words[i] = capitalize(w)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+func SnakeCase(s string) string {
+	return strings.ToLower(strings.Join(Words(s), "_"))
+}
+
+func KebabCase(s string) string {
+	return strings.ToLower(strings.Join(Words(s), "-"))
+}
+
+func TitleCase(s string) string {
+	words := Words(s)
+	for i, w := range words {
+		words[i] = capitalize(w)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,76 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPClient is shared by all calls of the http builtin.
+var HTTPClient = &http.Client{}
+
+// MaxHTTPBodySize limits size of response body read by the http builtin.
+var MaxHTTPBodySize int64 = 10 << 20
+
+// HTTP performs request to url and returns map with status, body and headers.
+// Supported options: method (default "GET"), headers (map of strings) and
+// body (string, or any value encoded as JSON).
+//
+// The http builtin lets expression authors send requests from the host
+// to arbitrary addresses, including internal network. It is disabled by
+// default and in sandbox mode, enable it only for trusted expressions.
+func HTTP(ctx context.Context, timeout time.Duration, url string, options map[string]any) (map[string]any, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	method := http.MethodGet
+	if m, ok := options["method"].(string); ok {
+		method = strings.ToUpper(m)
+	}
+	var body io.Reader
+	switch b := options["body"].(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if headers, ok := options["headers"].(map[string]any); ok {
+		for k, v := range headers {
+			req.Header.Set(k, fmt.Sprint(v))
+		}
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxHTTPBodySize))
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]any, len(resp.Header))
+	for k, v := range resp.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+	return map[string]any{
+		"status":  resp.StatusCode,
+		"body":    string(data),
+		"headers": headers,
+	}, nil
+}
@@ -0,0 +1,417 @@
+package builtin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/expr/vm/runtime"
+)
+
+// JSONPath evaluates a minimal subset of JSONPath against data and returns
+// all matched values. Supported: $, .field, ['field'], [N], [*], .*,
+// recursive descent .. and filters like [?(@.age > 18 && @.name)].
+func JSONPath(data any, path string) ([]any, error) {
+	p := &jsonPathParser{src: path}
+	steps, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []any{data}
+	for _, s := range steps {
+		if s.recursive {
+			var all []any
+			for _, n := range nodes {
+				all = descendants(n, all)
+			}
+			nodes = all
+		}
+		var next []any
+		for _, n := range nodes {
+			next = s.apply(n, next)
+		}
+		nodes = next
+	}
+	if nodes == nil {
+		nodes = []any{}
+	}
+	return nodes, nil
+}
+
+type jsonPathStep struct {
+	recursive bool
+	wildcard  bool
+	name      string
+	index     *int
+	filter    jsonPathExpr
+}
+
+func (s jsonPathStep) apply(node any, out []any) []any {
+	switch {
+	case s.wildcard:
+		return append(out, children(node)...)
+	case s.index != nil:
+		v := deref(reflect.ValueOf(node))
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return out
+		}
+		i := *s.index
+		if i < 0 {
+			i += v.Len()
+		}
+		if i >= 0 && i < v.Len() {
+			out = append(out, v.Index(i).Interface())
+		}
+		return out
+	case s.filter != nil:
+		for _, c := range children(node) {
+			if truthy(s.filter.eval(c)) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	if v, ok := field(node, s.name); ok {
+		out = append(out, v)
+	}
+	return out
+}
+
+func field(node any, name string) (any, bool) {
+	v := deref(reflect.ValueOf(node))
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	case reflect.Struct:
+		value := v.FieldByName(name)
+		if !value.IsValid() || !value.CanInterface() {
+			return nil, false
+		}
+		return value.Interface(), true
+	}
+	return nil, false
+}
+
+func children(node any) []any {
+	v := deref(reflect.ValueOf(node))
+	var out []any
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, v.Index(i).Interface())
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			out = append(out, v.MapIndex(k).Interface())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				out = append(out, v.Field(i).Interface())
+			}
+		}
+	}
+	return out
+}
+
+func descendants(node any, out []any) []any {
+	out = append(out, node)
+	for _, c := range children(node) {
+		out = descendants(c, out)
+	}
+	return out
+}
+
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+func (p *jsonPathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid jsonpath %q at %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jsonPathParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *jsonPathParser) skip(s string) bool {
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jsonPathParser) spaces() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *jsonPathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(".[]()=!<>&|@' \"", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *jsonPathParser) parse() ([]jsonPathStep, error) {
+	if !p.skip("$") {
+		return nil, p.errorf("must start with $")
+	}
+	return p.steps(false)
+}
+
+// steps parses selectors; inside filters it stops at the first character
+// that cannot continue a path.
+func (p *jsonPathParser) steps(inFilter bool) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	for p.pos < len(p.src) {
+		s := jsonPathStep{recursive: p.skip("..")}
+		switch {
+		case s.recursive && !p.peek("["), p.skip("."):
+			if p.skip("*") {
+				s.wildcard = true
+			} else if s.name = p.name(); s.name == "" {
+				return nil, p.errorf("expected field name")
+			}
+		case p.skip("["):
+			if err := p.bracket(&s); err != nil {
+				return nil, err
+			}
+		default:
+			if inFilter {
+				return steps, nil
+			}
+			return nil, p.errorf("unexpected %q", p.src[p.pos])
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+func (p *jsonPathParser) bracket(s *jsonPathStep) error {
+	p.spaces()
+	switch {
+	case p.skip("*"):
+		s.wildcard = true
+	case p.skip("?("):
+		expr, err := p.or()
+		if err != nil {
+			return err
+		}
+		p.spaces()
+		if !p.skip(")") {
+			return p.errorf("expected )")
+		}
+		s.filter = expr
+	case p.peek("'") || p.peek(`"`):
+		str, err := p.str()
+		if err != nil {
+			return err
+		}
+		s.name = str
+	default:
+		start := p.pos
+		p.skip("-")
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		i, err := strconv.Atoi(p.src[start:p.pos])
+		if err != nil {
+			return p.errorf("expected index")
+		}
+		s.index = &i
+	}
+	p.spaces()
+	if !p.skip("]") {
+		return p.errorf("expected ]")
+	}
+	return nil
+}
+
+func (p *jsonPathParser) str() (string, error) {
+	quote := p.src[p.pos]
+	end := strings.IndexByte(p.src[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+type jsonPathExpr interface {
+	eval(node any) any
+}
+
+type jsonPathLiteral struct{ value any }
+
+type jsonPathCurrent struct{ steps []jsonPathStep }
+
+type jsonPathBinary struct {
+	op          string
+	left, right jsonPathExpr
+}
+
+func (e jsonPathLiteral) eval(any) any { return e.value }
+
+func (e jsonPathCurrent) eval(node any) any {
+	nodes := []any{node}
+	for _, s := range e.steps {
+		var next []any
+		for _, n := range nodes {
+			next = s.apply(n, next)
+		}
+		nodes = next
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+func (e jsonPathBinary) eval(node any) (out any) {
+	a := e.left.eval(node)
+	switch e.op {
+	case "&&":
+		return truthy(a) && truthy(e.right.eval(node))
+	case "||":
+		return truthy(a) || truthy(e.right.eval(node))
+	}
+	b := e.right.eval(node)
+	defer func() {
+		if recover() != nil {
+			out = false // Values of different types never match.
+		}
+	}()
+	switch e.op {
+	case "==":
+		return runtime.Equal(a, b)
+	case "!=":
+		return !runtime.Equal(a, b)
+	case "<":
+		return runtime.Less(a, b)
+	case "<=":
+		return runtime.LessOrEqual(a, b)
+	case ">":
+		return runtime.More(a, b)
+	case ">=":
+		return runtime.MoreOrEqual(a, b)
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return v != nil
+}
+
+func (p *jsonPathParser) or() (jsonPathExpr, error) {
+	left, err := p.and()
+	for err == nil {
+		p.spaces()
+		if !p.skip("||") {
+			break
+		}
+		var right jsonPathExpr
+		right, err = p.and()
+		left = jsonPathBinary{op: "||", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *jsonPathParser) and() (jsonPathExpr, error) {
+	left, err := p.comparison()
+	for err == nil {
+		p.spaces()
+		if !p.skip("&&") {
+			break
+		}
+		var right jsonPathExpr
+		right, err = p.comparison()
+		left = jsonPathBinary{op: "&&", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *jsonPathParser) comparison() (jsonPathExpr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.spaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.skip(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return jsonPathBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jsonPathParser) operand() (jsonPathExpr, error) {
+	p.spaces()
+	switch {
+	case p.skip("("):
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		p.spaces()
+		if !p.skip(")") {
+			return nil, p.errorf("expected )")
+		}
+		return e, nil
+	case p.skip("@"):
+		steps, err := p.steps(true)
+		return jsonPathCurrent{steps: steps}, err
+	case p.peek("'") || p.peek(`"`):
+		s, err := p.str()
+		return jsonPathLiteral{s}, err
+	}
+	if p.pos < len(p.src) && strings.ContainsRune("-0123456789", rune(p.src[p.pos])) {
+		start := p.pos
+		for p.pos < len(p.src) && strings.ContainsRune("-+.eE0123456789", rune(p.src[p.pos])) {
+			p.pos++
+		}
+		number := p.src[start:p.pos]
+		if i, err := strconv.Atoi(number); err == nil {
+			return jsonPathLiteral{i}, nil
+		}
+		if f, err := strconv.ParseFloat(number, 64); err == nil {
+			return jsonPathLiteral{f}, nil
+		}
+		return nil, p.errorf("invalid number %q", number)
+	}
+	word := p.name()
+	switch word {
+	case "true":
+		return jsonPathLiteral{true}, nil
+	case "false":
+		return jsonPathLiteral{false}, nil
+	case "null":
+		return jsonPathLiteral{nil}, nil
+	}
+	return nil, p.errorf("unexpected %q", word)
+}
@@ -0,0 +1,90 @@
+package builtin
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/oarkflow/expr/ast"
+)
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	time.RFC822,
+	time.RFC850,
+	time.RFC1123,
+}
+
+// InLocation returns variant of date builtin which uses loc instead of
+// default timezone, or false if builtin does not depend on timezone.
+func InLocation(name string, loc *time.Location) (*ast.Function, bool) {
+	i, ok := Index[name]
+	if !ok {
+		return nil, false
+	}
+	fn := *Builtins[i]
+	switch name {
+	case "now":
+		fn.Func = nowIn(loc)
+	case "date":
+		fn.Func = dateIn(loc)
+	default:
+		return nil, false
+	}
+	return &fn, true
+}
+
+// nowIn returns current time in loc, or local time if loc is nil.
+func nowIn(loc *time.Location) func(args ...any) (any, error) {
+	return func(args ...any) (any, error) {
+		now := time.Now()
+		if loc != nil {
+			now = now.In(loc)
+		}
+		if len(args) > 0 {
+			layout := args[0].(string)
+			if slices.Contains(dateLayouts, layout) {
+				return now.Format(layout), nil
+			}
+		}
+		return now, nil
+	}
+}
+
+// dateIn parses dates without explicit timezone in loc, or in UTC if loc is nil.
+func dateIn(loc *time.Location) func(args ...any) (any, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return func(args ...any) (any, error) {
+		date := args[0].(string)
+		if len(args) == 2 {
+			layout := args[1].(string)
+			return time.ParseInLocation(layout, date, loc)
+		}
+		if len(args) == 3 {
+			layout := args[1].(string)
+			timeZone := args[2].(string)
+			tz, err := time.LoadLocation(timeZone)
+			if err != nil {
+				return nil, err
+			}
+			t, err := time.ParseInLocation(layout, date, tz)
+			if err != nil {
+				return nil, err
+			}
+			return t, nil
+		}
+
+		for _, layout := range dateLayouts {
+			t, err := time.ParseInLocation(layout, date, loc)
+			if err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date %s", date)
+	}
+}
@@ -7,8 +7,10 @@ import (
 
 var (
 	anyType     = reflect.TypeOf(new(any)).Elem()
+	boolType    = reflect.TypeOf(true)
 	integerType = reflect.TypeOf(0)
 	floatType   = reflect.TypeOf(float64(0))
+	stringType  = reflect.TypeOf("")
 	arrayType   = reflect.TypeOf([]any{})
 	mapType     = reflect.TypeOf(map[any]any{})
 )
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 
 	"github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/builtin"
@@ -11,6 +12,7 @@ import (
 	"github.com/oarkflow/expr/file"
 	"github.com/oarkflow/expr/parser"
 	"github.com/oarkflow/expr/vm"
+	"github.com/oarkflow/expr/vm/runtime"
 )
 
 func Check(tree *parser.Tree, config *conf.Config) (t reflect.Type, err error) {
@@ -74,6 +76,10 @@ type info struct {
 	method bool
 	fn     *ast.Function
 
+	// function is true for functions defined in expression.
+	// They are called by the VM itself and not through reflect.
+	function bool
+
 	// elem is element type of array or map.
 	// Arrays created with type []any, but
 	// we would like to detect expressions
@@ -120,6 +126,10 @@ func (v *checker) visit(node ast.Node) (reflect.Type, info) {
 		t, i = v.PointerNode(n)
 	case *ast.VariableDeclaratorNode:
 		t, i = v.VariableDeclaratorNode(n)
+	case *ast.BlockNode:
+		t, i = v.BlockNode(n)
+	case *ast.FunctionNode:
+		t, i = v.FunctionNode(n)
 	case *ast.ConditionalNode:
 		t, i = v.ConditionalNode(n)
 	case *ast.ArrayNode:
@@ -128,6 +138,8 @@ func (v *checker) visit(node ast.Node) (reflect.Type, info) {
 		t, i = v.MapNode(n)
 	case *ast.PairNode:
 		t, i = v.PairNode(n)
+	case *ast.SpreadNode:
+		t, i = v.error(n, "spread operator is allowed only in arguments of builtins")
 	default:
 		panic(fmt.Sprintf("undefined node type (%T)", node))
 	}
@@ -158,9 +170,11 @@ func (v *checker) IdentifierNode(node *ast.IdentifierNode) (reflect.Type, info)
 		return mapType, info{}
 	}
 	if fn, ok := v.config.Builtins[node.Value]; ok {
+		node.Func = fn
 		return functionType, info{fn: fn}
 	}
 	if fn, ok := v.config.Functions[node.Value]; ok {
+		node.Func = fn
 		return functionType, info{fn: fn}
 	}
 	if t, ok := v.config.Types[node.Value]; ok {
@@ -232,25 +246,48 @@ func (v *checker) UnaryNode(node *ast.UnaryNode) (reflect.Type, info) {
 }
 
 func (v *checker) BinaryNode(node *ast.BinaryNode) (reflect.Type, info) {
-	l, _ := v.visit(node.Left)
+	l, li := v.visit(node.Left)
 	r, ri := v.visit(node.Right)
 
 	l = deref(l)
 	r = deref(r)
 
 	// check operator overloading
-	if fns, ok := v.config.Operators[node.Operator]; ok {
+	fns, ok := v.config.Operators[node.Operator]
+	if !ok && strings.HasPrefix(node.Operator, "not ") {
+		fns, ok = v.config.Operators[strings.TrimPrefix(node.Operator, "not ")]
+	}
+	if ok {
 		t, _, ok := conf.FindSuitableOperatorOverload(fns, v.config.Types, l, r)
 		if ok {
 			return t, info{}
 		}
 	}
 
+	if v.config.StrictMode {
+		if err := runtime.StrictOperands(node.Operator, l, r); err != nil {
+			return v.error(node, "%v", err)
+		}
+	}
+
 	switch node.Operator {
+	case ">>", "<<":
+		for _, fi := range []info{li, ri} {
+			if fi.fn != nil && fi.fn.Predicate {
+				return v.error(node, "builtin %v cannot be composed", fi.fn.Name)
+			}
+		}
+		if (isFunc(l) || isAny(l)) && (isFunc(r) || isAny(r)) {
+			return reflect.FuncOf([]reflect.Type{anyType}, []reflect.Type{anyType}, false), info{function: true}
+		}
+
 	case "==", "!=":
 		if isComparable(l, r) {
 			return boolType, info{}
 		}
+		if isTime(l) && isString(r) || isString(l) && isTime(r) {
+			return boolType, info{}
+		}
 
 	case "or", "||", "and", "&&":
 		if isBool(l) && isBool(r) {
@@ -270,6 +307,9 @@ func (v *checker) BinaryNode(node *ast.BinaryNode) (reflect.Type, info) {
 		if isTime(l) && isTime(r) {
 			return boolType, info{}
 		}
+		if isTime(l) && isString(r) || isString(l) && isTime(r) {
+			return boolType, info{}
+		}
 		if or(l, r, isNumber, isString, isTime) {
 			return boolType, info{}
 		}
@@ -337,7 +377,7 @@ func (v *checker) BinaryNode(node *ast.BinaryNode) (reflect.Type, info) {
 			return anyType, info{}
 		}
 
-	case "in":
+	case "in", "not in":
 		if (isString(l) || isAny(l)) && isStruct(r) {
 			return boolType, info{}
 		}
@@ -384,7 +424,8 @@ func (v *checker) BinaryNode(node *ast.BinaryNode) (reflect.Type, info) {
 			return boolType, info{}
 		}
 
-	case "contains", "startsWith", "endsWith":
+	case "contains", "not contains", "startsWith", "endsWith", "equalsIgnoreCase",
+		"semverEq", "semverGt", "semverGte", "semverLt", "semverLte":
 		if isString(l) && isString(r) {
 			return boolType, info{}
 		}
@@ -421,6 +462,10 @@ func (v *checker) BinaryNode(node *ast.BinaryNode) (reflect.Type, info) {
 
 	}
 
+	if v.config.TypeConverter != nil {
+		// Operands are converted at runtime.
+		return anyType, info{}
+	}
 	return v.error(node, `invalid operation: %v (mismatched types %v and %v)`, node.Operator, l, r)
 }
 
@@ -466,6 +511,12 @@ func (v *checker) MemberNode(node *ast.MemberNode) (reflect.Type, info) {
 				return m.Type, info{method: true}
 			}
 		}
+		// Methods with pointer receivers are fetched by name at runtime.
+		if kind(base) != reflect.Ptr && kind(base) != reflect.Interface {
+			if m, ok := reflect.PointerTo(base).MethodByName(name.Value); ok {
+				return m.Type, info{method: true}
+			}
+		}
 	}
 
 	if kind(base) == reflect.Ptr {
@@ -491,7 +542,7 @@ func (v *checker) MemberNode(node *ast.MemberNode) (reflect.Type, info) {
 	case reflect.Struct:
 		if name, ok := node.Property.(*ast.StringNode); ok {
 			propertyName := name.Value
-			if field, ok := fetchField(base, propertyName); ok {
+			if field, ok := fetchField(base, propertyName, v.config.TagName); ok {
 				node.FieldIndex = field.Index
 				node.Name = propertyName
 				return field.Type, info{}
@@ -538,6 +589,12 @@ func (v *checker) SliceNode(node *ast.SliceNode) (reflect.Type, info) {
 func (v *checker) CallNode(node *ast.CallNode) (reflect.Type, info) {
 	fn, fnInfo := v.visit(node.Callee)
 
+	for _, arg := range node.Arguments {
+		if spread, ok := arg.(*ast.SpreadNode); ok {
+			return v.error(spread, "spread operator is allowed only in arguments of builtins")
+		}
+	}
+
 	if fnInfo.fn != nil {
 		node.Func = fnInfo.fn
 		return v.checkFunction(fnInfo.fn, node, node.Arguments)
@@ -556,6 +613,33 @@ func (v *checker) CallNode(node *ast.CallNode) (reflect.Type, info) {
 	case reflect.Interface:
 		return anyType, info{}
 	case reflect.Func:
+		if fnInfo.function {
+			if n := len(node.Arguments); n < fn.NumIn() {
+				// Partial application: check given arguments and
+				// return a function expecting the rest of them.
+				in := make([]reflect.Type, fn.NumIn())
+				for i := range in {
+					in[i] = fn.In(i)
+				}
+				out := []reflect.Type{fn.Out(0)}
+				given := reflect.FuncOf(in[:n], out, false)
+				if _, err := v.checkArguments(fnName, given, false, node.Arguments, node); err != nil {
+					if v.err == nil {
+						v.err = err
+					}
+					return anyType, info{}
+				}
+				return reflect.FuncOf(in[n:], out, false), info{function: true}
+			}
+			outType, err := v.checkArguments(fnName, fn, false, node.Arguments, node)
+			if err != nil {
+				if v.err == nil {
+					v.err = err
+				}
+				return anyType, info{}
+			}
+			return outType, info{}
+		}
 		inputParamsCount := 1 // for functions
 		if fnInfo.method {
 			inputParamsCount = 2 // for methods
@@ -589,6 +673,9 @@ func (v *checker) CallNode(node *ast.CallNode) (reflect.Type, info) {
 }
 
 func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
+	if node.HasSpread() {
+		return v.checkSpread(node)
+	}
 	switch node.Name {
 	case "all", "none", "any", "one":
 		collection, _ := v.visit(node.Arguments[0])
@@ -596,7 +683,7 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			return v.error(node.Arguments[0], "builtin %v takes only array (got %v)", node.Name, collection)
 		}
 
-		v.begin(collection)
+		v.begin(collection, scopeVar{"index", integerType})
 		closure, _ := v.visit(node.Arguments[1])
 		v.end()
 
@@ -617,7 +704,7 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			return v.error(node.Arguments[0], "builtin %v takes only array (got %v)", node.Name, collection)
 		}
 
-		v.begin(collection)
+		v.begin(collection, scopeVar{"index", integerType})
 		closure, _ := v.visit(node.Arguments[1])
 		v.end()
 
@@ -659,7 +746,7 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			return v.error(node.Arguments[0], "builtin %v takes only array (got %v)", node.Name, collection)
 		}
 
-		v.begin(collection)
+		v.begin(collection, scopeVar{"index", integerType})
 		closure, _ := v.visit(node.Arguments[1])
 		v.end()
 
@@ -680,10 +767,15 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			return v.error(node.Arguments[0], "builtin %v takes only array (got %v)", node.Name, collection)
 		}
 
-		v.begin(collection)
+		v.begin(collection, scopeVar{"index", integerType})
 		closure, _ := v.visit(node.Arguments[1])
 		v.end()
 
+		var def reflect.Type
+		if len(node.Arguments) == 3 {
+			def, _ = v.visit(node.Arguments[2])
+		}
+
 		if isFunc(closure) &&
 			closure.NumOut() == 1 &&
 			closure.NumIn() == 1 && isAny(closure.In(0)) {
@@ -691,7 +783,7 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			if !isBool(closure.Out(0)) && !isAny(closure.Out(0)) {
 				return v.error(node.Arguments[1], "predicate should return boolean (got %v)", closure.Out(0).String())
 			}
-			if isAny(collection) {
+			if isAny(collection) || (def != nil && def != collection.Elem()) {
 				return anyType, info{}
 			}
 			return collection.Elem(), info{}
@@ -704,7 +796,7 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			return v.error(node.Arguments[0], "builtin %v takes only array (got %v)", node.Name, collection)
 		}
 
-		v.begin(collection)
+		v.begin(collection, scopeVar{"index", integerType})
 		closure, _ := v.visit(node.Arguments[1])
 		v.end()
 
@@ -725,7 +817,7 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			return v.error(node.Arguments[0], "builtin %v takes only array (got %v)", node.Name, collection)
 		}
 
-		v.begin(collection)
+		v.begin(collection, scopeVar{"index", integerType})
 		closure, _ := v.visit(node.Arguments[1])
 		v.end()
 
@@ -733,6 +825,9 @@ func (v *checker) BuiltinNode(node *ast.BuiltinNode) (reflect.Type, info) {
 			closure.NumOut() == 1 &&
 			closure.NumIn() == 1 && isAny(closure.In(0)) {
 
+			if v.config.GroupByOrdered {
+				return reflect.TypeOf([]runtime.GroupEntry{}), info{}
+			}
 			return reflect.TypeOf(map[any][]any{}), info{}
 		}
 		return v.error(node.Arguments[1], "predicate should has one input and one output param")
@@ -786,6 +881,28 @@ func (v *checker) end() {
 	v.predicateScopes = v.predicateScopes[:len(v.predicateScopes)-1]
 }
 
+// checkSpread checks call of builtin with spread arguments. Number of
+// arguments is known only during evaluation, so result is any.
+func (v *checker) checkSpread(node *ast.BuiltinNode) (reflect.Type, info) {
+	id, ok := builtin.Index[node.Name]
+	if !ok || builtin.Builtins[id].Predicate || builtin.Builtins[id].Func == nil && builtin.Builtins[id].Fast == nil {
+		return v.error(node, "cannot spread arguments of %v", node.Name)
+	}
+	for _, arg := range node.Arguments {
+		spread, ok := arg.(*ast.SpreadNode)
+		if !ok {
+			v.visit(arg)
+			continue
+		}
+		t, _ := v.visit(spread.Node)
+		if !isArray(t) && !isAny(t) {
+			return v.error(spread, "cannot spread %v", t)
+		}
+		spread.SetType(t)
+	}
+	return anyType, info{}
+}
+
 func (v *checker) checkBuiltinGet(node *ast.BuiltinNode) (reflect.Type, info) {
 	if len(node.Arguments) != 2 {
 		return v.error(node, "invalid number of arguments (expected 2, got %d)", len(node.Arguments))
@@ -921,7 +1038,16 @@ func (v *checker) checkArguments(name string, fn reflect.Type, method bool, argu
 	}
 
 	for i, arg := range arguments {
-		t, _ := v.visit(arg)
+		var t reflect.Type
+		var ti info
+		if _, ok := arg.(*ast.ClosureNode); ok {
+			// Closure passed as an argument gets its input through #.
+			v.begin(anyType)
+			t, ti = v.visit(arg)
+			v.end()
+		} else {
+			t, ti = v.visit(arg)
+		}
 
 		var in reflect.Type
 		if fn.IsVariadic() && i >= fnNumIn-1 {
@@ -946,6 +1072,17 @@ func (v *checker) checkArguments(name string, fn reflect.Type, method bool, argu
 			continue
 		}
 
+		if ti.function && kind(in) == reflect.Func {
+			// Functions defined in expression are converted on call.
+			if isFunc(t) && in.NumIn() != t.NumIn() {
+				return anyType, &file.Error{
+					Location: arg.Location(),
+					Message:  fmt.Sprintf("cannot use %v as argument (type %v) to call %v ", t, in, name),
+				}
+			}
+			continue
+		}
+
 		if !t.AssignableTo(in) && kind(t) != reflect.Interface {
 			return anyType, &file.Error{
 				Location: arg.Location(),
@@ -960,8 +1097,10 @@ func (v *checker) checkArguments(name string, fn reflect.Type, method bool, argu
 func traverseAndReplaceIntegerNodesWithFloatNodes(node *ast.Node, newType reflect.Type) {
 	switch (*node).(type) {
 	case *ast.IntegerNode:
-		*node = &ast.FloatNode{Value: float64((*node).(*ast.IntegerNode).Value)}
+		integer := (*node).(*ast.IntegerNode)
+		*node = &ast.FloatNode{Value: float64(integer.Value)}
 		(*node).SetType(newType)
+		ast.KeepComment(integer, *node)
 	case *ast.UnaryNode:
 		unaryNode := (*node).(*ast.UnaryNode)
 		traverseAndReplaceIntegerNodesWithFloatNodes(&unaryNode.Node, newType)
@@ -993,11 +1132,24 @@ func traverseAndReplaceIntegerNodesWithIntegerNodes(node *ast.Node, newType refl
 }
 
 func (v *checker) ClosureNode(node *ast.ClosureNode) (reflect.Type, info) {
-	t, _ := v.visit(node.Node)
+	t, i := v.visit(node.Node)
 	if t == nil {
 		return v.error(node.Node, "closure cannot be nil")
 	}
-	return reflect.FuncOf([]reflect.Type{anyType}, []reflect.Type{t}, false), info{}
+	if i.function {
+		// Function used as predicate, like map(list, add(10)),
+		// is applied to the current element.
+		pointer := &ast.PointerNode{}
+		pointer.SetLocation(node.Node.Location())
+		call := &ast.CallNode{
+			Callee:    node.Node,
+			Arguments: []ast.Node{pointer},
+		}
+		call.SetLocation(node.Node.Location())
+		node.Node = call
+		t, _ = v.visit(node.Node)
+	}
+	return reflect.FuncOf([]reflect.Type{anyType}, []reflect.Type{t}, false), info{function: true}
 }
 
 func (v *checker) PointerNode(node *ast.PointerNode) (reflect.Type, info) {
@@ -1023,25 +1175,76 @@ func (v *checker) PointerNode(node *ast.PointerNode) (reflect.Type, info) {
 }
 
 func (v *checker) VariableDeclaratorNode(node *ast.VariableDeclaratorNode) (reflect.Type, info) {
+	if !v.declare(node) {
+		return anyType, info{}
+	}
+	t, i := v.visit(node.Expr)
+	v.varScopes = v.varScopes[:len(v.varScopes)-1]
+	return t, i
+}
+
+// declare checks the value of a let declaration and adds the variable to scope.
+func (v *checker) declare(node *ast.VariableDeclaratorNode) bool {
 	if _, ok := v.config.Types[node.Name]; ok {
-		return v.error(node, "cannot redeclare %v", node.Name)
+		v.error(node, "cannot redeclare %v", node.Name)
+		return false
 	}
 	if _, ok := v.config.Functions[node.Name]; ok {
-		return v.error(node, "cannot redeclare function %v", node.Name)
+		v.error(node, "cannot redeclare function %v", node.Name)
+		return false
 	}
 	if _, ok := v.config.Builtins[node.Name]; ok {
-		return v.error(node, "cannot redeclare builtin %v", node.Name)
+		v.error(node, "cannot redeclare builtin %v", node.Name)
+		return false
 	}
 	if _, ok := v.lookupVariable(node.Name); ok {
-		return v.error(node, "cannot redeclare variable %v", node.Name)
+		v.error(node, "cannot redeclare variable %v", node.Name)
+		return false
 	}
 	vtype, vinfo := v.visit(node.Value)
 	v.varScopes = append(v.varScopes, varScope{node.Name, vtype, vinfo})
-	t, i := v.visit(node.Expr)
-	v.varScopes = v.varScopes[:len(v.varScopes)-1]
+	return true
+}
+
+func (v *checker) BlockNode(node *ast.BlockNode) (reflect.Type, info) {
+	size := len(v.varScopes)
+	for _, stmt := range node.Statements {
+		if let, ok := stmt.(*ast.VariableDeclaratorNode); ok && let.Expr == nil {
+			if !v.declare(let) {
+				break
+			}
+			let.SetType(let.Value.Type())
+		} else {
+			v.visit(stmt)
+		}
+	}
+	t, i := v.visit(node.Result)
+	v.varScopes = v.varScopes[:size]
 	return t, i
 }
 
+func (v *checker) FunctionNode(node *ast.FunctionNode) (reflect.Type, info) {
+	in := make([]reflect.Type, len(node.Params))
+	for i := range in {
+		in[i] = anyType
+	}
+	scopes := len(v.varScopes)
+	if node.Name != "" {
+		// Return type is not known yet, so recursive calls return any.
+		self := reflect.FuncOf(in, []reflect.Type{anyType}, false)
+		v.varScopes = append(v.varScopes, varScope{node.Name, self, info{function: true}})
+	}
+	for _, param := range node.Params {
+		v.varScopes = append(v.varScopes, varScope{param, anyType, info{}})
+	}
+	t, _ := v.visit(node.Body)
+	v.varScopes = v.varScopes[:scopes]
+	if t == nil {
+		t = anyType
+	}
+	return reflect.FuncOf(in, []reflect.Type{t}, false), info{function: true}
+}
+
 func (v *checker) lookupVariable(name string) (varScope, bool) {
 	for i := len(v.varScopes) - 1; i >= 0; i-- {
 		if v.varScopes[i].name == name {
@@ -1099,6 +1302,9 @@ func (v *checker) MapNode(node *ast.MapNode) (reflect.Type, info) {
 	for _, pair := range node.Pairs {
 		v.visit(pair)
 	}
+	if v.config.OrderedMaps {
+		return anyType, info{}
+	}
 	return mapType, info{}
 }
 
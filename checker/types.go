@@ -4,7 +4,7 @@ import (
 	"reflect"
 	"time"
 
-	"github.com/oarkflow/expr/conf"
+	"github.com/oarkflow/expr/vm/runtime"
 )
 
 var (
@@ -177,13 +177,13 @@ func isFunc(t reflect.Type) bool {
 	return false
 }
 
-func fetchField(t reflect.Type, name string) (reflect.StructField, bool) {
+func fetchField(t reflect.Type, name, tag string) (reflect.StructField, bool) {
 	if t != nil {
 		// First check all structs fields.
 		for i := 0; i < t.NumField(); i++ {
 			field := t.Field(i)
 			// Search all fields, even embedded structs.
-			if conf.FieldName(field) == name {
+			if runtime.FieldName(field, tag) == name || field.Name == name {
 				return field, true
 			}
 		}
@@ -192,7 +192,7 @@ func fetchField(t reflect.Type, name string) (reflect.StructField, bool) {
 		for i := 0; i < t.NumField(); i++ {
 			anon := t.Field(i)
 			if anon.Anonymous {
-				if field, ok := fetchField(anon.Type, name); ok {
+				if field, ok := fetchField(anon.Type, name, tag); ok {
 					field.Index = append(anon.Index, field.Index...)
 					return field, true
 				}
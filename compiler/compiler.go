@@ -3,12 +3,15 @@ package compiler
 import (
 	"fmt"
 	"reflect"
+	"slices"
+	"time"
 
 	"github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/builtin"
 	"github.com/oarkflow/expr/conf"
 	"github.com/oarkflow/expr/file"
 	"github.com/oarkflow/expr/parser"
+	"github.com/oarkflow/expr/parser/utils"
 	. "github.com/oarkflow/expr/vm"
 	"github.com/oarkflow/expr/vm/runtime"
 )
@@ -34,6 +37,13 @@ func Compile(tree *parser.Tree, config *conf.Config) (program *Program, err erro
 	if config != nil {
 		c.mapEnv = config.MapEnv
 		c.cast = config.Expect
+		c.tco = config.TCO
+		c.groupByOrdered = config.GroupByOrdered
+		c.orderedMaps = config.OrderedMaps
+		c.strictSemVer = config.StrictSemVer
+		c.httpTimeout = config.HTTPTimeout
+		c.timezone = config.Timezone
+		c.resolver = config.IdentifierResolver != nil
 	}
 
 	c.compile(tree.Node)
@@ -57,6 +67,18 @@ func Compile(tree *parser.Tree, config *conf.Config) (program *Program, err erro
 		Arguments: c.arguments,
 		Functions: c.functions,
 		DebugInfo: c.debugInfo,
+		Spans:     c.spans,
+	}
+	if config != nil {
+		program.OnIdentifierAccess = config.OnIdentifierAccess
+		program.OnPanic = config.OnPanic
+		program.IdentifierResolver = config.IdentifierResolver
+		program.TagName = config.TagName
+		program.TimeLayout = config.TimeLayout
+		program.StrictMode = config.StrictMode
+		program.TypeConverter = config.TypeConverter
+		program.FunctionCallLimit = config.FunctionCallLimit
+		program.LocationPrefix = config.LocationPrefix
 	}
 	return
 }
@@ -73,7 +95,17 @@ type compiler struct {
 	debugInfo      map[string]string
 	mapEnv         bool
 	cast           reflect.Kind
+	tco            bool
+	groupByOrdered bool
+	orderedMaps    bool
+	strictSemVer   bool
+	httpTimeout    time.Duration
+	timezone       *time.Location
+	resolver       bool // identifiers are looked up by Program.IdentifierResolver
+	tailCalls      map[*ast.CallNode]*FunctionInfo
+	pointers       []int // variable for # of closures passed as values, -1 for predicates
 	nodes          []ast.Node
+	spans          []Span
 	chains         [][]int
 	arguments      []int
 }
@@ -162,6 +194,27 @@ func (c *compiler) emitFunction(fn *ast.Function, argsLen int) {
 	}
 }
 
+// emitFunctionValue pushes a builtin or function on the stack, so it can
+// be passed around and called later like any other value.
+func (c *compiler) emitFunctionValue(fn *ast.Function) {
+	if fn.Func == nil && fn.Fast != nil {
+		fast := fn.Fast
+		fn = &ast.Function{
+			Name: fn.Name,
+			Func: func(args ...any) (any, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("invalid number of arguments for %v (expected 1, got %d)", fn.Name, len(args))
+				}
+				return fast(args[0]), nil
+			},
+		}
+	}
+	if fn.Func == nil || fn.Context {
+		panic(fmt.Sprintf("builtin %v cannot be used as value", fn.Name))
+	}
+	c.emit(OpLoadFunc, c.addFunction(fn))
+}
+
 // addFunction adds builtin.Function.Func to the program.Functions and returns its index.
 func (c *compiler) addFunction(fn *ast.Function) int {
 	if fn == nil {
@@ -188,8 +241,11 @@ func (c *compiler) calcBackwardJump(to int) int {
 
 func (c *compiler) compile(node ast.Node) {
 	c.nodes = append(c.nodes, node)
+	span := len(c.spans)
+	c.spans = append(c.spans, Span{Node: node, Start: len(c.bytecode)})
 	defer func() {
 		c.nodes = c.nodes[:len(c.nodes)-1]
+		c.spans[span].End = len(c.bytecode)
 	}()
 
 	switch n := node.(type) {
@@ -227,6 +283,10 @@ func (c *compiler) compile(node ast.Node) {
 		c.PointerNode(n)
 	case *ast.VariableDeclaratorNode:
 		c.VariableDeclaratorNode(n)
+	case *ast.BlockNode:
+		c.BlockNode(n)
+	case *ast.FunctionNode:
+		c.FunctionNode(n)
 	case *ast.ConditionalNode:
 		c.ConditionalNode(n)
 	case *ast.ArrayNode:
@@ -253,7 +313,13 @@ func (c *compiler) IdentifierNode(node *ast.IdentifierNode) {
 		c.emit(OpLoadEnv)
 		return
 	}
-	if c.mapEnv {
+	if node.Func != nil {
+		c.emitFunctionValue(node.Func)
+		return
+	}
+	if c.resolver {
+		c.emit(OpLoadConst, c.addConstant(node.Value))
+	} else if c.mapEnv {
 		c.emit(OpLoadFast, c.addConstant(node.Value))
 	} else if len(node.FieldIndex) > 0 {
 		c.emit(OpLoadField, c.addConstant(&runtime.Field{
@@ -471,12 +537,15 @@ func (c *compiler) BinaryNode(node *ast.BinaryNode) {
 		c.derefInNeeded(node.Right)
 		c.emit(OpExponent)
 
-	case "in":
+	case "in", "not in":
 		c.compile(node.Left)
 		c.derefInNeeded(node.Left)
 		c.compile(node.Right)
 		c.derefInNeeded(node.Right)
 		c.emit(OpIn)
+		if node.Operator == "not in" {
+			c.emit(OpNot)
+		}
 
 	case "matches":
 		if node.Regexp != nil {
@@ -491,12 +560,15 @@ func (c *compiler) BinaryNode(node *ast.BinaryNode) {
 			c.emit(OpMatches)
 		}
 
-	case "contains":
+	case "contains", "not contains":
 		c.compile(node.Left)
 		c.derefInNeeded(node.Left)
 		c.compile(node.Right)
 		c.derefInNeeded(node.Right)
 		c.emit(OpContains)
+		if node.Operator == "not contains" {
+			c.emit(OpNot)
+		}
 
 	case "startsWith":
 		c.compile(node.Left)
@@ -512,6 +584,25 @@ func (c *compiler) BinaryNode(node *ast.BinaryNode) {
 		c.derefInNeeded(node.Right)
 		c.emit(OpEndsWith)
 
+	case "equalsIgnoreCase":
+		c.compile(node.Left)
+		c.derefInNeeded(node.Left)
+		c.compile(node.Right)
+		c.derefInNeeded(node.Right)
+		c.emit(OpEqualFold)
+
+	case "semverEq", "semverGt", "semverGte", "semverLt", "semverLte":
+		c.compile(node.Left)
+		c.derefInNeeded(node.Left)
+		c.compile(node.Right)
+		c.derefInNeeded(node.Right)
+		// Argument holds index of operator and strict flag in the lowest bit.
+		arg := slices.Index(runtime.SemVerOperators, node.Operator) << 1
+		if c.strictSemVer {
+			arg |= 1
+		}
+		c.emit(OpSemVer, arg)
+
 	case "..":
 		c.compile(node.Left)
 		c.derefInNeeded(node.Left)
@@ -528,6 +619,9 @@ func (c *compiler) BinaryNode(node *ast.BinaryNode) {
 		c.derefInNeeded(node.Right)
 		c.patchJump(end)
 
+	case ">>", "<<":
+		c.emitCompose(node)
+
 	default:
 		panic(fmt.Sprintf("unknown operator (%v)", node.Operator))
 
@@ -547,12 +641,22 @@ func (c *compiler) ChainNode(node *ast.ChainNode) {
 func (c *compiler) MemberNode(node *ast.MemberNode) {
 	if node.Method {
 		c.compile(node.Node)
+		if node.Optional {
+			ph := c.emit(OpJumpIfNil, placeholder)
+			c.chains[len(c.chains)-1] = append(c.chains[len(c.chains)-1], ph)
+		}
 		c.emit(OpMethod, c.addConstant(&runtime.Method{
 			Name:  node.Name,
 			Index: node.MethodIndex,
 		}))
 		return
 	}
+	if c.resolver {
+		if path, ok := c.envPath(node); ok {
+			c.emit(OpLoadConst, c.addConstant(path))
+			return
+		}
+	}
 	op := OpFetch
 	index := node.FieldIndex
 	path := []string{node.Name}
@@ -597,6 +701,32 @@ func (c *compiler) MemberNode(node *ast.MemberNode) {
 	}
 }
 
+// envPath returns dotted path of member access on environment identifier,
+// like "user.address.city", resolved by Program.IdentifierResolver.
+func (c *compiler) envPath(node ast.Node) (string, bool) {
+	switch n := node.(type) {
+	case *ast.IdentifierNode:
+		if _, ok := c.lookupVariable(n.Value); ok || n.Value == "$env" || n.Func != nil || n.Method {
+			return "", false
+		}
+		return n.Value, true
+	case *ast.MemberNode:
+		if n.Optional || n.Method {
+			return "", false
+		}
+		name, ok := n.Property.(*ast.StringNode)
+		if !ok || !utils.IsValidIdentifier(name.Value) {
+			return "", false
+		}
+		base, ok := c.envPath(n.Node)
+		if !ok {
+			return "", false
+		}
+		return base + "." + name.Value, true
+	}
+	return "", false
+}
+
 func (c *compiler) SliceNode(node *ast.SliceNode) {
 	c.compile(node.Node)
 	if node.To != nil {
@@ -614,24 +744,69 @@ func (c *compiler) SliceNode(node *ast.SliceNode) {
 
 func (c *compiler) CallNode(node *ast.CallNode) {
 	for _, arg := range node.Arguments {
-		c.compile(arg)
+		if closure, ok := arg.(*ast.ClosureNode); ok {
+			c.emitClosure(closure)
+		} else {
+			c.compile(arg)
+		}
+	}
+	if fn, ok := c.tailCalls[node]; ok {
+		// Reuse the current call: store new arguments and start over.
+		for i := len(fn.Params) - 1; i >= 0; i-- {
+			c.emit(OpStore, fn.Params[i])
+		}
+		c.emit(OpJumpBackward, c.calcBackwardJump(fn.Entry))
+		return
 	}
 	if node.Func != nil {
 		c.emitFunction(node.Func, len(node.Arguments))
 		return
 	}
+	jumps := 0
+	if len(c.chains) > 0 {
+		jumps = len(c.chains[len(c.chains)-1])
+	}
 	c.compile(node.Callee)
 	if node.Typed > 0 {
 		c.emit(OpCallTyped, node.Typed)
-		return
 	} else if node.Fast {
 		c.emit(OpCallFast, len(node.Arguments))
 	} else {
 		c.emit(OpCall, len(node.Arguments))
 	}
+	if len(c.chains) > 0 && len(node.Arguments) > 0 {
+		c.dropArgumentsOnNil(jumps, len(node.Arguments))
+	}
+}
+
+// dropArgumentsOnNil redirects jumps of an optional chain made while
+// compiling the callee, like obj?.method(a, b), so the already evaluated
+// arguments are removed from the stack before leaving the chain.
+func (c *compiler) dropArgumentsOnNil(from, args int) {
+	chain := c.chains[len(c.chains)-1]
+	if len(chain) == from {
+		return
+	}
+	jumps := append([]int{}, chain[from:]...)
+	c.chains[len(c.chains)-1] = chain[:from]
+
+	end := c.emit(OpJump, placeholder)
+	for _, ph := range jumps {
+		c.patchJump(ph)
+	}
+	for i := 0; i <= args; i++ {
+		c.emit(OpPop)
+	}
+	c.emit(OpNil)
+	c.chains[len(c.chains)-1] = append(c.chains[len(c.chains)-1], c.emit(OpJump, placeholder))
+	c.patchJump(end)
 }
 
 func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
+	if node.HasSpread() {
+		c.emitSpreadCall(node)
+		return
+	}
 	switch node.Name {
 	case "all":
 		c.compile(node.Arguments[0])
@@ -639,6 +814,7 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 		var loopBreak int
 		c.emitLoop(func() {
 			c.compile(node.Arguments[1])
+			// Stop at the first element not matching the predicate.
 			loopBreak = c.emit(OpJumpIfFalse, placeholder)
 			c.emit(OpPop)
 		})
@@ -654,6 +830,7 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 		c.emitLoop(func() {
 			c.compile(node.Arguments[1])
 			c.emit(OpNot)
+			// Stop at the first matching element.
 			loopBreak = c.emit(OpJumpIfFalse, placeholder)
 			c.emit(OpPop)
 		})
@@ -668,6 +845,7 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 		var loopBreak int
 		c.emitLoop(func() {
 			c.compile(node.Arguments[1])
+			// Stop at the first matching element.
 			loopBreak = c.emit(OpJumpIfTrue, placeholder)
 			c.emit(OpPop)
 		})
@@ -754,6 +932,8 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 		if node.Throws {
 			c.emit(OpPush, c.addConstant(fmt.Errorf("reflect: slice index out of range")))
 			c.emit(OpThrow)
+		} else if len(node.Arguments) == 3 {
+			c.compile(node.Arguments[2])
 		} else {
 			c.emit(OpNil)
 		}
@@ -799,6 +979,8 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 		if node.Throws {
 			c.emit(OpPush, c.addConstant(fmt.Errorf("reflect: slice index out of range")))
 			c.emit(OpThrow)
+		} else if len(node.Arguments) == 3 {
+			c.compile(node.Arguments[2])
 		} else {
 			c.emit(OpNil)
 		}
@@ -831,7 +1013,11 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 			c.compile(node.Arguments[1])
 			c.emit(OpGroupBy)
 		})
-		c.emit(OpGetGroupBy)
+		if c.groupByOrdered {
+			c.emit(OpGetOrderedGroupBy)
+		} else {
+			c.emit(OpGetGroupBy)
+		}
 		c.emit(OpEnd)
 		return
 
@@ -842,6 +1028,16 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 			c.compile(node.Arguments[2])
 			c.emit(OpSetAcc)
 		} else {
+			// Without initial value the first element becomes the
+			// accumulator, which is impossible for an empty array.
+			c.emit(OpGetLen)
+			c.emitPush(0)
+			c.emit(OpEqualInt)
+			notEmpty := c.emit(OpJumpIfFalse, placeholder)
+			c.emit(OpPush, c.addConstant(fmt.Errorf("reduce of empty array with no initial value")))
+			c.emit(OpThrow)
+			c.patchJump(notEmpty)
+			c.emit(OpPop)
 			c.emit(OpPointer)
 			c.emit(OpIncrementIndex)
 			c.emit(OpSetAcc)
@@ -858,13 +1054,27 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 
 	if id, ok := builtin.Index[node.Name]; ok {
 		f := builtin.Builtins[id]
+		size := len(node.Arguments)
+		if f.Context {
+			c.emit(OpLoadContext)
+			size++
+		}
+		if f.HTTPTimeout {
+			c.emitPush(c.httpTimeout)
+			size++
+		}
 		for _, arg := range node.Arguments {
 			c.compile(arg)
 		}
+		if c.timezone != nil {
+			if fn, ok := builtin.InLocation(node.Name, c.timezone); ok {
+				f = fn
+			}
+		}
 		if f.Fast != nil {
 			c.emit(OpCallBuiltin1, id)
 		} else if f.Func != nil {
-			c.emitFunction(f, len(node.Arguments))
+			c.emitFunction(f, size)
 		}
 		return
 	}
@@ -872,6 +1082,44 @@ func (c *compiler) BuiltinNode(node *ast.BuiltinNode) {
 	panic(fmt.Sprintf("unknown builtin %v", node.Name))
 }
 
+// emitSpreadCall calls builtin with arguments collected into arrays,
+// which are flattened by OpCallSpread.
+func (c *compiler) emitSpreadCall(node *ast.BuiltinNode) {
+	f := builtin.Builtins[builtin.Index[node.Name]]
+	if c.timezone != nil {
+		if fn, ok := builtin.InLocation(node.Name, c.timezone); ok {
+			f = fn
+		}
+	}
+	if f.Func != nil {
+		c.emit(OpLoadFunc, c.addFunction(f))
+	} else {
+		c.emitFunctionValue(f)
+	}
+	groups := 0
+	single := func(compile func()) {
+		compile()
+		c.emitPush(1)
+		c.emit(OpArray)
+		groups++
+	}
+	if f.Context {
+		single(func() { c.emit(OpLoadContext) })
+	}
+	if f.HTTPTimeout {
+		single(func() { c.emitPush(c.httpTimeout) })
+	}
+	for _, arg := range node.Arguments {
+		if spread, ok := arg.(*ast.SpreadNode); ok {
+			c.compile(spread.Node)
+			groups++
+		} else {
+			single(func() { c.compile(arg) })
+		}
+	}
+	c.emit(OpCallSpread, groups)
+}
+
 func (c *compiler) emitCond(body func()) {
 	noop := c.emit(OpJumpIfFalse, placeholder)
 	c.emit(OpPop)
@@ -914,10 +1162,71 @@ func (c *compiler) emitLoopBackwards(body func()) {
 }
 
 func (c *compiler) ClosureNode(node *ast.ClosureNode) {
+	c.pointers = append(c.pointers, -1)
 	c.compile(node.Node)
+	c.pointers = c.pointers[:len(c.pointers)-1]
+}
+
+// emitClosure compiles closure passed as an argument into a function
+// value, which takes a single argument available as # inside the closure.
+func (c *compiler) emitClosure(node *ast.ClosureNode) {
+	c.nodes = append(c.nodes, node)
+	defer func() {
+		c.nodes = c.nodes[:len(c.nodes)-1]
+	}()
+
+	skip := c.emit(OpJump, placeholder)
+	fn := &FunctionInfo{
+		Entry:  len(c.bytecode),
+		Self:   -1,
+		Params: []int{c.addVariable("#")},
+	}
+	c.pointers = append(c.pointers, fn.Params[0])
+	c.compile(node.Node)
+	c.pointers = c.pointers[:len(c.pointers)-1]
+	c.emit(OpReturn)
+
+	c.patchJump(skip)
+	c.emit(OpFunction, c.addConstant(fn))
+}
+
+// emitCompose compiles f >> g into a function { g(f(#)) } and f << g
+// into { f(g(#)) }. Both operands are evaluated once and kept in variables.
+func (c *compiler) emitCompose(node *ast.BinaryNode) {
+	c.compile(node.Left)
+	left := c.addVariable(node.Operator)
+	c.emit(OpStore, left)
+	c.compile(node.Right)
+	right := c.addVariable(node.Operator)
+	c.emit(OpStore, right)
+
+	first, second := left, right
+	if node.Operator == "<<" {
+		first, second = right, left
+	}
+
+	skip := c.emit(OpJump, placeholder)
+	fn := &FunctionInfo{
+		Entry:  len(c.bytecode),
+		Self:   -1,
+		Params: []int{c.addVariable("#")},
+	}
+	c.emit(OpLoadVar, fn.Params[0])
+	c.emit(OpLoadVar, first)
+	c.emit(OpCall, 1)
+	c.emit(OpLoadVar, second)
+	c.emit(OpCall, 1)
+	c.emit(OpReturn)
+
+	c.patchJump(skip)
+	c.emit(OpFunction, c.addConstant(fn))
 }
 
 func (c *compiler) PointerNode(node *ast.PointerNode) {
+	if n := len(c.pointers); n > 0 && c.pointers[n-1] >= 0 && node.Name == "" {
+		c.emit(OpLoadVar, c.pointers[n-1])
+		return
+	}
 	switch node.Name {
 	case "index":
 		c.emit(OpGetIndex)
@@ -939,6 +1248,26 @@ func (c *compiler) VariableDeclaratorNode(node *ast.VariableDeclaratorNode) {
 	c.endScope()
 }
 
+func (c *compiler) BlockNode(node *ast.BlockNode) {
+	scopes := 0
+	for _, stmt := range node.Statements {
+		if let, ok := stmt.(*ast.VariableDeclaratorNode); ok && let.Expr == nil {
+			c.compile(let.Value)
+			index := c.addVariable(let.Name)
+			c.emit(OpStore, index)
+			c.beginScope(let.Name, index)
+			scopes++
+		} else {
+			c.compile(stmt)
+			c.emit(OpPop)
+		}
+	}
+	c.compile(node.Result)
+	for ; scopes > 0; scopes-- {
+		c.endScope()
+	}
+}
+
 func (c *compiler) beginScope(name string, index int) {
 	c.scopes = append(c.scopes, scope{name, index})
 }
@@ -956,6 +1285,71 @@ func (c *compiler) lookupVariable(name string) (int, bool) {
 	return 0, false
 }
 
+func (c *compiler) FunctionNode(node *ast.FunctionNode) {
+	skip := c.emit(OpJump, placeholder)
+
+	fn := &FunctionInfo{
+		Name:  node.Name,
+		Entry: len(c.bytecode),
+		Self:  -1,
+	}
+	scopes := 0
+	if node.Name != "" {
+		fn.Self = c.addVariable(node.Name)
+		c.beginScope(node.Name, fn.Self)
+		scopes++
+	}
+	for _, param := range node.Params {
+		index := c.addVariable(param)
+		fn.Params = append(fn.Params, index)
+		c.beginScope(param, index)
+		scopes++
+	}
+
+	if c.tco {
+		c.markTailCalls(fn, node.Body)
+	}
+	c.compile(node.Body)
+	c.emit(OpReturn)
+
+	for ; scopes > 0; scopes-- {
+		c.endScope()
+	}
+	c.patchJump(skip)
+	c.emit(OpFunction, c.addConstant(fn))
+}
+
+// markTailCalls finds calls of fn to itself in tail position of its body.
+// Such calls are compiled into a jump to the start of the body.
+func (c *compiler) markTailCalls(fn *FunctionInfo, node ast.Node) {
+	if fn.Name == "" {
+		return
+	}
+	switch n := node.(type) {
+	case *ast.ConditionalNode:
+		c.markTailCalls(fn, n.Exp1)
+		c.markTailCalls(fn, n.Exp2)
+	case *ast.BlockNode:
+		c.markTailCalls(fn, n.Result)
+	case *ast.VariableDeclaratorNode:
+		if n.Name != fn.Name {
+			c.markTailCalls(fn, n.Expr)
+		}
+	case *ast.CallNode:
+		callee, ok := n.Callee.(*ast.IdentifierNode)
+		if !ok || callee.Value != fn.Name || len(n.Arguments) != len(fn.Params) {
+			return
+		}
+		if index, ok := c.lookupVariable(fn.Name); !ok || index != fn.Self {
+			return // shadowed by a parameter
+		}
+		if c.tailCalls == nil {
+			c.tailCalls = make(map[*ast.CallNode]*FunctionInfo)
+		}
+		c.tailCalls[n] = fn
+	}
+}
+
 func (c *compiler) ConditionalNode(node *ast.ConditionalNode) {
 	c.compile(node.Cond)
 	otherwise := c.emit(OpJumpIfFalse, placeholder)
@@ -986,7 +1380,11 @@ func (c *compiler) MapNode(node *ast.MapNode) {
 	}
 
 	c.emitPush(len(node.Pairs))
-	c.emit(OpMap)
+	if c.orderedMaps {
+		c.emit(OpOrderedMap)
+	} else {
+		c.emit(OpMap)
+	}
 }
 
 func (c *compiler) PairNode(node *ast.PairNode) {
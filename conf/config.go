@@ -2,7 +2,9 @@ package conf
 
 import (
 	"fmt"
+	"io"
 	"reflect"
+	"time"
 
 	"github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/builtin"
@@ -10,35 +12,77 @@ import (
 )
 
 type Config struct {
-	Env         any
-	Types       TypesTable
-	MapEnv      bool
-	DefaultType reflect.Type
-	Operators   OperatorsTable
-	Expect      reflect.Kind
-	ExpectAny   bool
-	Optimize    bool
-	Strict      bool
-	ConstFns    map[string]reflect.Value
-	Visitors    []ast.Visitor
-	Functions   map[string]*ast.Function
-	Builtins    map[string]*ast.Function
-	Disabled    map[string]bool // disabled builtins
+	Env               any
+	Types             TypesTable
+	MapEnv            bool
+	DefaultType       reflect.Type
+	Operators         OperatorsTable
+	Expect            reflect.Kind
+	ExpectAny         bool
+	Optimize          bool
+	DisableOptimizer  bool // skip all optimizer passes, for debugging
+	Strict            bool
+	StrictMode        bool           // operators reject operands of mismatched types instead of converting them
+	TCO               bool           // rewrite self-recursive tail calls into jumps
+	GroupByOrdered    bool           // groupBy returns []runtime.GroupEntry in order of first appearance
+	OrderedMaps       bool           // map literals build *runtime.OrderedMap keeping order of keys
+	StrictSemVer      bool           // semver operators require MAJOR.MINOR.PATCH and honor pre-release tags
+	HTTPTimeout       time.Duration  // timeout of requests made by http builtin
+	TagName           string         // struct tag with names of fields, "json" is used as fallback
+	TimeLayout        string         // layout of strings compared with time.Time values
+	Timezone          *time.Location // timezone of now and date builtins, local time and UTC by default
+	MaxParseDepth     int            // maximum nesting of expressions, DefaultMaxParseDepth if zero
+	Verbose           io.Writer      // receives a line for every node replaced by optimizer
+	Parallelism       int            // number of workers of EvalMany, GOMAXPROCS if zero
+	FunctionCallLimit int            // maximum number of function calls per evaluation, unlimited if zero
+	LocationPrefix    bool           // errors are formatted as "line 3, col 7: message"
+	ConstFns          map[string]reflect.Value
+	Visitors          []ast.Visitor
+	Functions         map[string]*ast.Function
+	Builtins          map[string]*ast.Function
+	Disabled          map[string]bool                   // disabled builtins
+	Loader            func(name string) (string, error) // source of imported modules
+	PreludeExpr       string                            // let declarations prepended to every expression
+	// CustomLiteralParser parses literals starting with given prefixes,
+	// like $100.50 for "$", into constants.
+	CustomLiteralParser map[string]func(string) (any, error)
+	// OnIdentifierAccess is called with name and value of every identifier
+	// read from environment during evaluation.
+	OnIdentifierAccess func(name string, value any)
+	// OnPanic is called with value recovered from panic of a function
+	// call and the call node. The panic is returned as error regardless.
+	OnPanic func(recovered any, node ast.Node)
+	// TypeConverter computes result of binary operator for operands of
+	// types it is not defined on, instead of failing.
+	TypeConverter func(a, b any, operator string) (any, error)
+	// IdentifierResolver replaces lookup of identifiers in environment.
+	// Member access on identifiers is resolved as dotted path, like "user.name".
+	IdentifierResolver func(name string, env any) (any, bool)
 }
 
+// DefaultMaxParseDepth is default maximum nesting of parsed expressions.
+const DefaultMaxParseDepth = 200
+
 // CreateNew creates new config with default values.
 func CreateNew() *Config {
 	c := &Config{
-		Optimize:  true,
-		Operators: make(map[string][]string),
-		ConstFns:  make(map[string]reflect.Value),
-		Functions: make(map[string]*ast.Function),
-		Builtins:  make(map[string]*ast.Function),
-		Disabled:  make(map[string]bool),
+		Optimize:      true,
+		HTTPTimeout:   30 * time.Second,
+		TagName:       runtime.DefaultTagName,
+		TimeLayout:    time.RFC3339,
+		MaxParseDepth: DefaultMaxParseDepth,
+		Operators:     make(map[string][]string),
+		ConstFns:      make(map[string]reflect.Value),
+		Functions:     make(map[string]*ast.Function),
+		Builtins:      make(map[string]*ast.Function),
+		Disabled:      make(map[string]bool),
 	}
 	for _, f := range builtin.Builtins {
 		c.Builtins[f.Name] = f
 	}
+	for _, name := range DisabledByDefault {
+		c.Disabled[name] = true
+	}
 	return c
 }
 
@@ -61,12 +105,27 @@ func (c *Config) WithEnv(env any) {
 	}
 
 	c.Env = env
-	c.Types = CreateTypesTable(env)
+	c.Types = c.createTypesTable()
 	c.MapEnv = mapEnv
 	c.DefaultType = mapValueType
 	c.Strict = true
 }
 
+func (c *Config) createTypesTable() TypesTable {
+	if e, ok := c.Env.(*runtime.Environment); ok {
+		return CreateTypesTableByTag(e.Map(), c.TagName)
+	}
+	return CreateTypesTableByTag(c.Env, c.TagName)
+}
+
+// WithTagName sets struct tag used for names of struct fields.
+func (c *Config) WithTagName(tag string) {
+	c.TagName = tag
+	if c.Env != nil {
+		c.Types = c.createTypesTable()
+	}
+}
+
 func (c *Config) Operator(operator string, fns ...string) {
 	c.Operators[operator] = append(c.Operators[operator], fns...)
 }
@@ -2,6 +2,7 @@ package conf
 
 import (
 	"reflect"
+	"strings"
 	
 	"github.com/oarkflow/expr/ast"
 )
@@ -41,6 +42,12 @@ func (p *OperatorPatcher) Visit(node *ast.Node) {
 	}
 	
 	fns, ok := p.Operators[binaryNode.Operator]
+	negate := false
+	if !ok && strings.HasPrefix(binaryNode.Operator, "not ") {
+		// Overloaded "in" also serves "not in".
+		fns, ok = p.Operators[strings.TrimPrefix(binaryNode.Operator, "not ")]
+		negate = true
+	}
 	if !ok {
 		return
 	}
@@ -50,10 +57,13 @@ func (p *OperatorPatcher) Visit(node *ast.Node) {
 	
 	_, fn, ok := FindSuitableOperatorOverload(fns, p.Types, leftType, rightType)
 	if ok {
-		newNode := &ast.CallNode{
+		var newNode ast.Node = &ast.CallNode{
 			Callee:    &ast.IdentifierNode{Value: fn},
 			Arguments: []ast.Node{binaryNode.Left, binaryNode.Right},
 		}
+		if negate {
+			newNode = &ast.UnaryNode{Operator: "not", Node: newNode}
+		}
 		ast.Patch(node, newNode)
 	}
 }
@@ -0,0 +1,45 @@
+package conf
+
+// Sandboxed lists builtins which expose information about host or network,
+// or allow to serialize large data structures, and are disabled in sandbox mode.
+var Sandboxed = []string{
+	"ipInCidr",
+	"ipVersion",
+	"ipToInt",
+	"intToIP",
+	"toJSON",
+	"fromJSON",
+	"env",
+	"sleep",
+	"timestamp",
+	"http",
+}
+
+// DisabledByDefault lists builtins which must be enabled explicitly with Allow.
+var DisabledByDefault = []string{
+	"env",
+	"sleep",
+	"timestamp",
+	"http",
+}
+
+// Allow enables builtins, including ones disabled by default.
+func (c *Config) Allow(names ...string) {
+	for _, name := range names {
+		delete(c.Disabled, name)
+	}
+}
+
+// Sandbox creates new config with Sandboxed builtins disabled.
+func Sandbox() *Config {
+	c := CreateNew()
+	c.Sandbox()
+	return c
+}
+
+// Sandbox disables Sandboxed builtins.
+func (c *Config) Sandbox() {
+	for _, name := range Sandboxed {
+		c.Disabled[name] = true
+	}
+}
@@ -2,6 +2,8 @@ package conf
 
 import (
 	"reflect"
+
+	"github.com/oarkflow/expr/vm/runtime"
 )
 
 type Tag struct {
@@ -21,6 +23,12 @@ type TypesTable map[string]Tag
 // If map is passed, all items will be treated as variables
 // (key as name, value as type).
 func CreateTypesTable(i any) TypesTable {
+	return CreateTypesTableByTag(i, runtime.DefaultTagName)
+}
+
+// CreateTypesTableByTag is CreateTypesTable which names struct fields
+// by given tag.
+func CreateTypesTableByTag(i any, tag string) TypesTable {
 	if i == nil {
 		return nil
 	}
@@ -36,7 +44,7 @@ func CreateTypesTable(i any) TypesTable {
 
 	switch d.Kind() {
 	case reflect.Struct:
-		types = FieldsFromStruct(d)
+		types = FieldsFromStructByTag(d, tag)
 
 		// Methods of struct should be gathered from original struct with pointer,
 		// as methods maybe declared on pointer receiver. Also this method retrieves
@@ -76,6 +84,11 @@ func CreateTypesTable(i any) TypesTable {
 }
 
 func FieldsFromStruct(t reflect.Type) TypesTable {
+	return FieldsFromStructByTag(t, runtime.DefaultTagName)
+}
+
+// FieldsFromStructByTag is FieldsFromStruct which names fields by given tag.
+func FieldsFromStructByTag(t reflect.Type, tag string) TypesTable {
 	types := make(TypesTable)
 	t = dereference(t)
 	if t == nil {
@@ -88,7 +101,7 @@ func FieldsFromStruct(t reflect.Type) TypesTable {
 			f := t.Field(i)
 
 			if f.Anonymous {
-				for name, typ := range FieldsFromStruct(f.Type) {
+				for name, typ := range FieldsFromStructByTag(f.Type, tag) {
 					if _, ok := types[name]; ok {
 						types[name] = Tag{Ambiguous: true}
 					} else {
@@ -97,13 +110,17 @@ func FieldsFromStruct(t reflect.Type) TypesTable {
 					}
 				}
 			}
-			if fn := FieldName(f); fn == "$env" { // Could check for all keywords here
+			if fn := runtime.FieldName(f, tag); fn == "$env" { // Could check for all keywords here
 				panic("attempt to misuse env keyword as env struct field tag")
 			} else {
-				types[FieldName(f)] = Tag{
+				types[fn] = Tag{
 					Type:       f.Type,
 					FieldIndex: f.Index,
 				}
+				if _, ok := types[f.Name]; !ok && f.IsExported() {
+					// Go name of tagged field is still accessible.
+					types[f.Name] = types[fn]
+				}
 			}
 		}
 	}
@@ -129,8 +146,5 @@ func kind(t reflect.Type) reflect.Kind {
 }
 
 func FieldName(field reflect.StructField) string {
-	if taggedName := field.Tag.Get("expr"); taggedName != "" {
-		return taggedName
-	}
-	return field.Name
+	return runtime.FieldName(field, runtime.DefaultTagName)
 }
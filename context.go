@@ -0,0 +1,94 @@
+package expr
+
+import (
+	"sync"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/conf"
+	"github.com/oarkflow/expr/parser"
+	"github.com/oarkflow/expr/vm"
+)
+
+// Context is a set of options, functions and constants used to compile
+// expressions. Unlike AddFunction, functions added to Context are visible
+// only to programs compiled by it, so every tenant of an application may
+// have its own Context.
+type Context struct {
+	mu        sync.RWMutex
+	options   []Option
+	functions map[string]func(params ...any) (any, error)
+	types     map[string][]any
+	constants map[string]any
+}
+
+// NewContext returns Context compiling expressions with opts.
+func NewContext(opts ...Option) *Context {
+	return &Context{
+		options:   opts,
+		functions: make(map[string]func(params ...any) (any, error)),
+		types:     make(map[string][]any),
+		constants: make(map[string]any),
+	}
+}
+
+// AddFunction registers function available to programs of the context.
+// The function can be either func(params ...any) (any, error) or any
+// other Go function.
+func (c *Context) AddFunction(name string, fn any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.functions[name], c.types[name] = functionOf(name, fn)
+}
+
+// AddConstant registers value available to programs of the context by
+// name. Values of environment take precedence over constants.
+func (c *Context) AddConstant(name string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.constants[name] = value
+}
+
+// Compile compiles input with options, functions and constants of the
+// context, followed by ops.
+func (c *Context) Compile(input string, ops ...Option) (*vm.Program, error) {
+	c.mu.RLock()
+	opts := append([]Option{}, c.options...)
+	for name, fn := range c.functions {
+		opts = append(opts, Function(name, fn, c.types[name]...))
+	}
+	constants := make(map[string]any, len(c.constants))
+	for name, value := range c.constants {
+		constants[name] = value
+	}
+	c.mu.RUnlock()
+
+	if len(constants) > 0 {
+		locals := &localNames{names: make(map[string]bool)}
+		if tree, err := parser.Parse(input); err == nil {
+			ast.Walk(&tree.Node, locals)
+		}
+		opts = append(opts, func(config *conf.Config) {
+			config.Visitors = append(config.Visitors, &contextConstants{
+				config:       config,
+				substitution: substitution{values: constants, locals: locals.names},
+			})
+		})
+	}
+	return Compile(input, append(opts, ops...)...)
+}
+
+// contextConstants substitutes constants for identifiers, which are not
+// defined in environment.
+type contextConstants struct {
+	config *conf.Config
+	substitution
+}
+
+func (v *contextConstants) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.IdentifierNode); ok {
+		if _, ok := v.config.Types[n.Value]; ok {
+			return
+		}
+	}
+	v.substitution.Visit(node)
+}
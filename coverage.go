@@ -0,0 +1,108 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/file"
+	"github.com/oarkflow/expr/vm"
+)
+
+// CoverageCase is environment to run program with and result it is
+// expected to return.
+type CoverageCase struct {
+	Env      any
+	Expected any
+}
+
+// NodePath identifies node of expression by its location in source.
+type NodePath struct {
+	Location   file.Location
+	Expression string
+}
+
+func (p NodePath) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Location.Line, p.Location.Column+1, p.Expression)
+}
+
+// CoverageReport shows which branches of expression were evaluated.
+// Branches are both arms of conditionals and operands of &&, || and ??.
+type CoverageReport struct {
+	TotalNodes     int
+	CoveredNodes   int
+	UncoveredNodes []NodePath
+	Failed         []int // indexes of cases which failed or returned unexpected result
+}
+
+// Coverage runs program with every case and reports branches of
+// expression never evaluated by any of them.
+func Coverage(program *vm.Program, cases []CoverageCase) *CoverageReport {
+	report := &CoverageReport{}
+	covered := make([]bool, len(program.Bytecode))
+	for i, c := range cases {
+		out, err := vm.RunCoverage(program, c.Env, covered)
+		if err != nil || !reflect.DeepEqual(out, c.Expected) {
+			report.Failed = append(report.Failed, i)
+		}
+	}
+
+	executed := make(map[file.Location]bool)
+	for ip, ok := range covered {
+		if ok {
+			executed[program.Locations[ip]] = true
+		}
+	}
+
+	if program.Node == nil {
+		return report
+	}
+	b := &branches{}
+	ast.Walk(&program.Node, b)
+	for _, node := range b.nodes {
+		report.TotalNodes++
+		if evaluated(node, executed) {
+			report.CoveredNodes++
+		} else {
+			report.UncoveredNodes = append(report.UncoveredNodes, NodePath{
+				Location:   node.Location(),
+				Expression: node.String(),
+			})
+		}
+	}
+	sort.SliceStable(report.UncoveredNodes, func(i, j int) bool {
+		a, b := report.UncoveredNodes[i].Location, report.UncoveredNodes[j].Location
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return report
+}
+
+// evaluated reports whether any instruction of node was executed.
+func evaluated(node ast.Node, executed map[file.Location]bool) bool {
+	return ast.FindFirst(node, func(n ast.Node) bool {
+		return executed[n.Location()]
+	}) != nil
+}
+
+// branches collects nodes which may be skipped during evaluation.
+type branches struct {
+	nodes []ast.Node
+}
+
+func (v *branches) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.ConditionalNode:
+		v.nodes = append(v.nodes, n.Exp1, n.Exp2)
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "&&", "and", "||", "or", "??":
+			// Operands of chains like a && b && c are collected once.
+			for _, operand := range []ast.Node{n.Left, n.Right} {
+				if o, ok := operand.(*ast.BinaryNode); !ok || o.Operator != n.Operator {
+					v.nodes = append(v.nodes, operand)
+				}
+			}
+		}
+	}
+}
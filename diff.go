@@ -0,0 +1,161 @@
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/parser"
+)
+
+// ExprDiff is semantic difference between two versions of expression.
+type ExprDiff struct {
+	AddedIdentifiers   []string
+	RemovedIdentifiers []string
+	AddedOperators     []string
+	RemovedOperators   []string
+	Changes            []ast.DiffEntry
+	Summary            string // like "amount threshold lowered from 100 to 90, added check on `status`"
+}
+
+// Diff parses both versions of expression and describes what changed
+// between them.
+func Diff(before, after string) (*ExprDiff, error) {
+	a, err := parser.Parse(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parser.Parse(after)
+	if err != nil {
+		return nil, err
+	}
+	x, y := collectNames(a.Node), collectNames(b.Node)
+	d := &ExprDiff{
+		AddedIdentifiers:   difference(y.identifiers, x.identifiers),
+		RemovedIdentifiers: difference(x.identifiers, y.identifiers),
+		AddedOperators:     difference(y.operators, x.operators),
+		RemovedOperators:   difference(x.operators, y.operators),
+		Changes:            ast.Diff(a.Node, b.Node),
+	}
+	summary := make([]string, 0, len(d.Changes))
+	for _, change := range d.Changes {
+		summary = append(summary, describeChange(change))
+	}
+	d.Summary = strings.Join(summary, ", ")
+	return d, nil
+}
+
+func describeChange(change ast.DiffEntry) string {
+	switch change.Kind {
+	case ast.Added:
+		return "added " + subject(change.After)
+	case ast.Removed:
+		return "removed " + subject(change.Before)
+	}
+
+	if x, ok := change.Before.(*ast.BinaryNode); ok {
+		if y, ok := change.After.(*ast.BinaryNode); ok && x.Operator != y.Operator {
+			return fmt.Sprintf("operator of `%s` changed from %s to %s", y.Left, x.Operator, y.Operator)
+		}
+	}
+
+	what := fmt.Sprintf("`%s`", change.Before)
+	if parent, ok := change.Parent.(*ast.BinaryNode); ok && comparison(parent.Operator) {
+		other := parent.Left
+		if other == change.After {
+			other = parent.Right
+		}
+		what = fmt.Sprintf("%s threshold", other)
+	}
+	a, aok := number(change.Before)
+	b, bok := number(change.After)
+	switch {
+	case aok && bok && b < a:
+		return fmt.Sprintf("%s lowered from %s to %s", what, change.Before, change.After)
+	case aok && bok && b > a:
+		return fmt.Sprintf("%s raised from %s to %s", what, change.Before, change.After)
+	}
+	return fmt.Sprintf("%s changed from %s to %s", what, change.Before, change.After)
+}
+
+// subject describes added or removed node by identifiers it uses.
+func subject(node ast.Node) string {
+	names := collectNames(node).identifiers
+	if len(names) == 0 {
+		return fmt.Sprintf("`%s`", node)
+	}
+	for i, name := range names {
+		names[i] = fmt.Sprintf("`%s`", name)
+	}
+	return "check on " + strings.Join(names, ", ")
+}
+
+func comparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+func number(node ast.Node) (float64, bool) {
+	switch n := node.(type) {
+	case *ast.IntegerNode:
+		return float64(n.Value), true
+	case *ast.FloatNode:
+		return n.Value, true
+	case *ast.UnaryNode:
+		if v, ok := number(n.Node); ok && n.Operator == "-" {
+			return -v, true
+		}
+	}
+	return 0, false
+}
+
+type names struct {
+	identifiers []string
+	operators   []string
+}
+
+func (v *names) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.identifiers = append(v.identifiers, n.Value)
+	case *ast.UnaryNode:
+		v.operators = append(v.operators, n.Operator)
+	case *ast.BinaryNode:
+		v.operators = append(v.operators, n.Operator)
+	}
+}
+
+// collectNames returns sorted unique identifiers and operators of node.
+func collectNames(node ast.Node) *names {
+	v := &names{}
+	ast.Walk(&node, v)
+	v.identifiers = unique(v.identifiers)
+	v.operators = unique(v.operators)
+	return v
+}
+
+func unique(s []string) []string {
+	sort.Strings(s)
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// difference returns elements of sorted a missing in sorted b.
+func difference(a, b []string) []string {
+	var out []string
+	for _, v := range a {
+		if i := sort.SearchStrings(b, v); i == len(b) || b[i] != v {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/oarkflow/expr"
+)
+
+func TestLocationPrefix(t *testing.T) {
+	_, err := expr.Compile("1 +\n  )", expr.LocationPrefix(true))
+	want := "line 2, col 3: unexpected token Bracket(\")\")\n |   )\n | ..^"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %v", err, want)
+	}
+
+	program, err := expr.Compile("let a = [1];\na[5]", expr.LocationPrefix(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = expr.Run(program, nil)
+	want = "line 2, col 2: reflect: slice index out of range\n | a[5]\n | .^"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,40 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oarkflow/expr/file"
+)
+
+// ExprError is an error of evaluation of expression Source.
+type ExprError struct {
+	Err    error
+	Source string
+}
+
+// WrapError attaches expression src to err, so the error reads like
+// "invalid operation at 1:5 in expression: 'x / 0'". Returns nil if err is
+// nil. Wrapped *file.Error is still available with errors.As.
+func WrapError(err error, src string) error {
+	if err == nil {
+		return nil
+	}
+	return &ExprError{Err: err, Source: src}
+}
+
+func (e *ExprError) Error() string {
+	message := e.Err.Error()
+	var fileError *file.Error
+	if errors.As(e.Err, &fileError) {
+		message = fileError.Message
+		if !fileError.Location.Empty() {
+			message = fmt.Sprintf("%s at %d:%d", message, fileError.Line, fileError.Column+1)
+		}
+	}
+	return fmt.Sprintf("%s in expression: '%s'", message, e.Source)
+}
+
+func (e *ExprError) Unwrap() error {
+	return e.Err
+}
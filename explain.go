@@ -0,0 +1,88 @@
+package expr
+
+import (
+	"context"
+	"time"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/compiler"
+	"github.com/oarkflow/expr/vm"
+)
+
+// EvalTree is value of expression node together with values of its
+// sub-expressions.
+type EvalTree struct {
+	Node     ast.Node
+	Value    any
+	Duration time.Duration
+	Children []*EvalTree
+	// Evaluated is false for nodes skipped by evaluation, like the right
+	// side of || when the left one is true, and for bodies of closures.
+	Evaluated bool
+	// Err is error of evaluation, set on the node which failed and on
+	// all nodes enclosing it.
+	Err error
+}
+
+// Explain evaluates input with env once and returns values of every
+// sub-expression as a tree mirroring the AST. Bodies of closures are not
+// explained, as they are evaluated once per element.
+func Explain(input string, env any, ops ...Option) (*EvalTree, error) {
+	tree, config, err := check(context.Background(), input, ops)
+	if err != nil {
+		return nil, err
+	}
+	program, err := compiler.Compile(tree, config)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[ast.Node]*EvalTree)
+	root := mirror(tree.Node, nodes)
+
+	type open struct {
+		tree  *EvalTree // nil for nodes which are not explained
+		start time.Time
+	}
+	var stack []open
+	_, _ = vm.RunTrace(context.Background(), program, env, func(event vm.TraceEvent) {
+		switch event.Type {
+		case vm.EventEnter:
+			stack = append(stack, open{tree: nodes[event.Node], start: time.Now()})
+		case vm.EventExit:
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if o.tree != nil {
+				o.tree.Value = event.Value
+				o.tree.Duration = time.Since(o.start)
+				o.tree.Evaluated = true
+			}
+		case vm.EventError:
+			err, _ := event.Value.(error)
+			for _, o := range stack {
+				if o.tree != nil {
+					o.tree.Err = err
+					o.tree.Duration = time.Since(o.start)
+					o.tree.Evaluated = true
+				}
+			}
+		}
+	})
+	return root, nil
+}
+
+// mirror builds tree of node without values, registering every node in
+// nodes. Closures are neither registered nor descended into.
+func mirror(node ast.Node, nodes map[ast.Node]*EvalTree) *EvalTree {
+	t := &EvalTree{Node: node}
+	if _, ok := node.(*ast.ClosureNode); ok {
+		return t
+	}
+	nodes[node] = t
+	for _, child := range ast.Children(node) {
+		if child != nil {
+			t.Children = append(t.Children, mirror(child, nodes))
+		}
+	}
+	return t
+}
@@ -1,9 +1,12 @@
 package expr
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/builtin"
@@ -13,19 +16,25 @@ import (
 	"github.com/oarkflow/expr/file"
 	"github.com/oarkflow/expr/optimizer"
 	"github.com/oarkflow/expr/parser"
+	"github.com/oarkflow/expr/parser/lexer"
 	"github.com/oarkflow/expr/vm"
+	"github.com/oarkflow/expr/vm/runtime"
 )
 
 type customFunction struct {
 	funcs map[string]func(params ...any) (any, error)
+	types map[string][]any
 	mu    *sync.RWMutex
 }
 
 var customFunctions *customFunction
 
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 func init() {
 	customFunctions = &customFunction{
 		funcs: make(map[string]func(params ...any) (any, error)),
+		types: make(map[string][]any),
 		mu:    &sync.RWMutex{},
 	}
 }
@@ -36,6 +45,73 @@ func AddFunction(name string, handler func(params ...any) (any, error)) {
 	customFunctions.funcs[name] = handler
 }
 
+// AddFunctionGroup registers functions available in expressions as
+// prefix.name(...), like math.abs(-5). Functions can be either
+// func(params ...any) (any, error) or any other Go function.
+func AddFunctionGroup(prefix string, fns map[string]any) {
+	customFunctions.mu.Lock()
+	defer customFunctions.mu.Unlock()
+	for name, fn := range fns {
+		name = prefix + "." + name
+		customFunctions.funcs[name], customFunctions.types[name] = functionOf(name, fn)
+	}
+}
+
+// customFunctionOptions returns options adding functions registered
+// with AddFunction and AddFunctionGroup.
+func customFunctionOptions() []Option {
+	customFunctions.mu.RLock()
+	defer customFunctions.mu.RUnlock()
+	var opts []Option
+	for name, handler := range customFunctions.funcs {
+		opts = append(opts, Function(name, handler, customFunctions.types[name]...))
+	}
+	return opts
+}
+
+// functionOf converts fn into function accepted by Function and its types.
+func functionOf(name string, fn any) (func(params ...any) (any, error), []any) {
+	if f, ok := fn.(func(params ...any) (any, error)); ok {
+		return f, nil
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("expr: %s is not a function", name))
+	}
+	t := v.Type()
+	handler := func(params ...any) (any, error) {
+		in := make([]reflect.Value, len(params))
+		for i, param := range params {
+			var pt reflect.Type
+			if t.IsVariadic() && i >= t.NumIn()-1 {
+				pt = t.In(t.NumIn() - 1).Elem()
+			} else {
+				pt = t.In(i)
+			}
+			if param == nil {
+				in[i] = reflect.Zero(pt)
+				continue
+			}
+			in[i] = reflect.ValueOf(param)
+			if !in[i].Type().AssignableTo(pt) && in[i].Type().ConvertibleTo(pt) {
+				in[i] = in[i].Convert(pt)
+			}
+		}
+		out := v.Call(in)
+		if n := len(out); n > 0 && t.Out(n-1) == errorType {
+			if !out[n-1].IsNil() {
+				return nil, out[n-1].Interface().(error)
+			}
+			out = out[:n-1]
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return out[0].Interface(), nil
+	}
+	return handler, []any{fn}
+}
+
 func AvailableFunctions() []string {
 	customFunctions.mu.Lock()
 	defer customFunctions.mu.Unlock()
@@ -47,11 +123,44 @@ func AvailableFunctions() []string {
 }
 
 func Parse(expr string) (*vm.Program, error) {
-	var opts []Option
-	for name, handler := range customFunctions.funcs {
-		opts = append(opts, Function(name, handler))
-	}
-	return Compile(expr, opts...)
+	return Compile(expr, customFunctionOptions()...)
+}
+
+// Tree is parsed expression.
+type Tree = parser.Tree
+
+// ParseTree parses input into a tree without type checking or compiling
+// it, like parser.Parse.
+func ParseTree(input string) (*Tree, error) {
+	return parser.Parse(input)
+}
+
+// ParseWithConfig parses input with config, like parser.ParseWithConfig.
+func ParseWithConfig(input string, config *conf.Config) (*Tree, error) {
+	return parser.ParseWithConfig(input, config)
+}
+
+// Environment is a chainable environment with lexical scoping.
+type Environment = runtime.Environment
+
+// NewEnvironment creates environment with bindings. Names missing in bindings
+// are looked up in parent, which is nil for root environment.
+func NewEnvironment(parent *Environment, bindings map[string]any) *Environment {
+	return runtime.NewEnvironment(parent, bindings)
+}
+
+// Scope is Environment, a frame of bindings linked to its parent.
+type Scope = Environment
+
+// NewScope creates scope with bindings inheriting from parent, which is
+// nil for root scope. Scopes are accepted as env by Eval and Run.
+func NewScope(parent *Scope, bindings map[string]any) *Scope {
+	return runtime.NewEnvironment(parent, bindings)
+}
+
+// EnvScope wraps flat env map into root scope.
+func EnvScope(m map[string]any) *Scope {
+	return runtime.NewEnvironment(nil, m)
 }
 
 // Option for configuring config.
@@ -140,6 +249,208 @@ func Optimize(b bool) Option {
 	}
 }
 
+// CustomLiteral makes words starting with prefix literals parsed by fn,
+// like $100.50 parsed into Money for prefix "$". Literal is prefix
+// followed by a digit and letters, digits, underscores and dots, or by a
+// quoted string, like ip"10.0.0.1"; fn receives it without the prefix and
+// quotes. Prefixes ending with a letter take only quoted strings, so
+// identifiers starting with them are not literals. Panics if prefix is
+// empty, contains digits, quotes or spaces, or clashes with $env.
+func CustomLiteral(prefix string, fn func(string) (any, error)) Option {
+	if err := lexer.CheckLiteralPrefix(prefix); err != nil {
+		panic(fmt.Sprintf("expr: %v", err))
+	}
+	return func(c *conf.Config) {
+		if c.CustomLiteralParser == nil {
+			c.CustomLiteralParser = make(map[string]func(string) (any, error))
+		}
+		c.CustomLiteralParser[prefix] = fn
+	}
+}
+
+// OnPanic sets fn called when a function called by expression panics,
+// with recovered value and the call node, for example to report it to
+// monitoring. The panic is returned as error of evaluation either way.
+func OnPanic(fn func(recovered any, node ast.Node)) Option {
+	return func(c *conf.Config) {
+		c.OnPanic = fn
+	}
+}
+
+// PreludeExpr sets let declarations, like "let pi = 3.14159;", available
+// in every compiled expression, as if prepended to its source.
+func PreludeExpr(prelude string) Option {
+	return func(c *conf.Config) {
+		c.PreludeExpr = prelude
+	}
+}
+
+// DisableOptimizer skips all optimizer passes, even if Optimize is on.
+// It helps to find out whether a bug is in optimizer or in evaluation.
+// A warning is written to the writer of OptionVerbose, if set, as
+// expressions run slower without optimizations.
+func DisableOptimizer() Option {
+	return func(c *conf.Config) {
+		c.DisableOptimizer = true
+	}
+}
+
+// TCO turns tail call optimization of self-recursive functions on or off.
+// A call of a named function to itself in tail position of its body, like
+// loop(n - 1, acc + 1) in `let loop = func(n, acc) { n == 0 ? acc :
+// loop(n - 1, acc + 1) }`, rebinds the parameters and jumps to the start
+// of the body instead of growing the call stack.
+func TCO(b bool) Option {
+	return func(c *conf.Config) {
+		c.TCO = b
+	}
+}
+
+// GroupByOrdered makes groupBy return a slice of groups ([]runtime.GroupEntry)
+// ordered by first appearance of the key, instead of a map.
+func GroupByOrdered(b bool) Option {
+	return func(c *conf.Config) {
+		c.GroupByOrdered = b
+	}
+}
+
+// WithTypeConverter sets fn computing result of arithmetic and comparison
+// operators for operands they are not defined on, like "1" + 2. It is
+// called only when evaluation would fail otherwise, and disables type
+// checking of such operators.
+func WithTypeConverter(fn func(a, b any, operator string) (any, error)) Option {
+	return func(c *conf.Config) {
+		c.TypeConverter = fn
+	}
+}
+
+// Parallelism sets number of programs evaluated concurrently by EvalMany.
+func Parallelism(n int) Option {
+	return func(c *conf.Config) {
+		c.Parallelism = n
+	}
+}
+
+// FunctionCallLimit limits number of function calls, of builtins, functions
+// from environment and functions defined in expression, during single
+// evaluation. It stops runaway recursion early. Zero means unlimited.
+func FunctionCallLimit(n int) Option {
+	return func(c *conf.Config) {
+		c.FunctionCallLimit = n
+	}
+}
+
+// StrictMode makes operators reject operands of mismatched types, like
+// 1 == "1" or 0 + "5", instead of converting them. Comparison of int and
+// float requires explicit conversion.
+func StrictMode(b bool) Option {
+	return func(c *conf.Config) {
+		c.StrictMode = b
+	}
+}
+
+// LocationPrefix formats compile and runtime errors as
+// "line 3, col 7: message" instead of "message (3:7)".
+func LocationPrefix(b bool) Option {
+	return func(c *conf.Config) {
+		c.LocationPrefix = b
+	}
+}
+
+// OrderedMaps makes map literals build *runtime.OrderedMap, which keeps keys
+// in order of declaration, so keys({b: 1, a: 2}) is ["b", "a"]. Such maps
+// are typed as interface by the checker.
+func OrderedMaps(b bool) Option {
+	return func(c *conf.Config) {
+		c.OrderedMaps = b
+	}
+}
+
+// StrictSemVer makes semver operators require full MAJOR.MINOR.PATCH versions
+// and take pre-release tags into account. By default pre-release tags are ignored.
+// Build metadata never affects comparison.
+func StrictSemVer(b bool) Option {
+	return func(c *conf.Config) {
+		c.StrictSemVer = b
+	}
+}
+
+// HTTPTimeout sets timeout of requests made by http builtin. The http builtin
+// is disabled by default, as it allows expressions to reach any address
+// accessible from the host; enable it with EnableBuiltin("http") only
+// for trusted expressions.
+func HTTPTimeout(d time.Duration) Option {
+	return func(c *conf.Config) {
+		c.HTTPTimeout = d
+	}
+}
+
+// OptionVerbose makes Compile print every replacement made by optimizer
+// to w, like "fold: patched IntegerNode at 1:3 (5 + 3 → 8)".
+func OptionVerbose(w io.Writer) Option {
+	return func(c *conf.Config) {
+		c.Verbose = w
+	}
+}
+
+// Timezone sets timezone used by date builtins: now returns time in loc
+// and date parses strings without explicit timezone in loc.
+func Timezone(loc *time.Location) Option {
+	return func(c *conf.Config) {
+		c.Timezone = loc
+	}
+}
+
+// OnIdentifierAccess sets callback called with name and value of every
+// identifier read from environment during evaluation.
+func OnIdentifierAccess(fn func(name string, value any)) Option {
+	return func(c *conf.Config) {
+		c.OnIdentifierAccess = fn
+	}
+}
+
+// IdentifierResolver sets function used to look up identifiers instead of
+// reading them from environment. Member access on identifiers, like user.name,
+// is passed as dotted path; if resolver reports it as not found, the path is
+// resolved field by field.
+func IdentifierResolver(fn func(name string, env any) (any, bool)) Option {
+	return func(c *conf.Config) {
+		c.IdentifierResolver = fn
+	}
+}
+
+// TagName sets struct tag with names of struct fields used in expressions.
+// Default is "expr"; "json" tag is used as a fallback.
+func TagName(tag string) Option {
+	return func(c *conf.Config) {
+		c.WithTagName(tag)
+	}
+}
+
+// TimeLayout sets layout of strings compared with time.Time values,
+// like `created_at > "2024-01-01T00:00:00Z"`. Default is time.RFC3339.
+func TimeLayout(layout string) Option {
+	return func(c *conf.Config) {
+		c.TimeLayout = layout
+	}
+}
+
+// MaxParseDepth sets maximum nesting of expressions accepted by parser.
+// Default is conf.DefaultMaxParseDepth.
+func MaxParseDepth(depth int) Option {
+	return func(c *conf.Config) {
+		c.MaxParseDepth = depth
+	}
+}
+
+// Loader sets function used to fetch source of modules imported with
+// `import name from "module"`.
+func Loader(fn func(name string) (string, error)) Option {
+	return func(c *conf.Config) {
+		c.Loader = fn
+	}
+}
+
 // Patch adds visitor to list of visitors what will be applied before compiling AST to bytecode.
 func Patch(visitor ast.Visitor) Option {
 	return func(c *conf.Config) {
@@ -169,6 +480,18 @@ func Function(name string, fn func(params ...any) (any, error), types ...any) Op
 	}
 }
 
+// FunctionGroup adds functions available in expressions as prefix.name(...).
+// See AddFunctionGroup.
+func FunctionGroup(prefix string, fns map[string]any) Option {
+	return func(c *conf.Config) {
+		for name, fn := range fns {
+			name = prefix + "." + name
+			handler, types := functionOf(name, fn)
+			Function(name, handler, types...)(c)
+		}
+	}
+}
+
 // DisableAllBuiltins disables all builtins.
 func DisableAllBuiltins() Option {
 	return func(c *conf.Config) {
@@ -178,6 +501,14 @@ func DisableAllBuiltins() Option {
 	}
 }
 
+// Sandbox disables builtins listed in conf.Sandboxed, which expose
+// information about host or network, or serialize data.
+func Sandbox() Option {
+	return func(c *conf.Config) {
+		c.Sandbox()
+	}
+}
+
 // DisableBuiltin disables builtin function.
 func DisableBuiltin(name string) Option {
 	return func(c *conf.Config) {
@@ -194,6 +525,70 @@ func EnableBuiltin(name string) Option {
 
 // Compile parses and compiles given input expression to bytecode program.
 func Compile(input string, ops ...Option) (*vm.Program, error) {
+	return CompileContext(context.Background(), input, ops...)
+}
+
+// CompileContext is Compile which stops parsing with error when ctx is done.
+func CompileContext(ctx context.Context, input string, ops ...Option) (*vm.Program, error) {
+	tree, config, err := check(ctx, input, ops)
+	if err == nil {
+		var program *vm.Program
+		program, err = compileTree(tree, config)
+		if err == nil {
+			return program, nil
+		}
+	}
+	if fileError, ok := err.(*file.Error); ok && config.LocationPrefix {
+		fileError.LocationPrefix = true
+	}
+	return nil, err
+}
+
+// compileTree optimizes and compiles checked tree.
+func compileTree(tree *parser.Tree, config *conf.Config) (*vm.Program, error) {
+	var err error
+	if config.Optimize {
+		if config.Verbose != nil {
+			var steps []optimizer.Step
+			steps, err = optimizer.Trace(&tree.Node, config)
+			for _, step := range steps {
+				_, _ = fmt.Fprintf(config.Verbose, "%s: patched %s at %d:%d (%s → %s)\n",
+					step.Pass, step.Type, step.Location.Line, step.Location.Column+1, step.Before, step.After)
+			}
+		} else {
+			_, err = optimizer.Optimize(&tree.Node, config)
+		}
+		if err != nil {
+			if fileError, ok := err.(*file.Error); ok {
+				return nil, fileError.Bind(tree.Source)
+			}
+			return nil, err
+		}
+	}
+
+	program, err := compiler.Compile(tree, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+// check parses and type checks input. Config is returned on error too.
+func check(ctx context.Context, input string, ops []Option) (*parser.Tree, *conf.Config, error) {
+	config := newConfig(ops)
+	tree, err := parser.ParseWithContext(ctx, input, config)
+	if err != nil {
+		return nil, config, err
+	}
+	if err := checkTree(tree, config); err != nil {
+		return nil, config, err
+	}
+	return tree, config, nil
+}
+
+// newConfig creates config with ops applied.
+func newConfig(ops []Option) *conf.Config {
 	config := conf.CreateNew()
 	for _, op := range ops {
 		op(config)
@@ -209,10 +604,16 @@ func Compile(input string, ops ...Option) (*vm.Program, error) {
 			Types:     config.Types,
 		})
 	}
+	return config
+}
 
-	tree, err := parser.ParseWithConfig(input, config)
-	if err != nil {
-		return nil, err
+// checkTree expands macros, applies visitors and type checks parsed tree.
+func checkTree(tree *parser.Tree, config *conf.Config) error {
+	if err := macroExpansion(&tree.Node); err != nil {
+		if fileError, ok := err.(*file.Error); ok {
+			return fileError.Bind(tree.Source)
+		}
+		return err
 	}
 
 	if len(config.Visitors) > 0 {
@@ -223,27 +624,44 @@ func Compile(input string, ops ...Option) (*vm.Program, error) {
 			ast.Walk(&tree.Node, v)
 		}
 	}
-	_, err = checker.Check(tree, config)
+	_, err := checker.Check(tree, config)
+	return err
+}
+
+// OptimizationReport lists transformations made by optimizer.
+type OptimizationReport struct {
+	Steps []OptimizationStep
+}
+
+// OptimizationStep is a subtree replaced by optimizer pass.
+type OptimizationStep struct {
+	PassName string
+	Before   string
+	After    string
+}
+
+// ExplainOptimization reports which optimizer passes changed input and how.
+func ExplainOptimization(input string, ops ...Option) (*OptimizationReport, error) {
+	tree, config, err := check(context.Background(), input, ops)
 	if err != nil {
 		return nil, err
 	}
-
-	if config.Optimize {
-		err = optimizer.Optimize(&tree.Node, config)
-		if err != nil {
-			if fileError, ok := err.(*file.Error); ok {
-				return nil, fileError.Bind(tree.Source)
-			}
-			return nil, err
-		}
-	}
-
-	program, err := compiler.Compile(tree, config)
+	steps, err := optimizer.Trace(&tree.Node, config)
 	if err != nil {
+		if fileError, ok := err.(*file.Error); ok {
+			return nil, fileError.Bind(tree.Source)
+		}
 		return nil, err
 	}
-
-	return program, nil
+	report := &OptimizationReport{}
+	for _, step := range steps {
+		report.Steps = append(report.Steps, OptimizationStep{
+			PassName: step.Pass,
+			Before:   step.Before,
+			After:    step.After,
+		})
+	}
+	return report, nil
 }
 
 // Run evaluates given bytecode program.
@@ -251,17 +669,25 @@ func Run(program *vm.Program, env any) (any, error) {
 	return vm.Run(program, env)
 }
 
+// RunContext evaluates given bytecode program with ctx. Builtins like sleep
+// stop when ctx is cancelled.
+func RunContext(ctx context.Context, program *vm.Program, env any) (any, error) {
+	return vm.RunContext(ctx, program, env)
+}
+
+// Memoize returns copy of program which returns cached results for ttl
+// when run with env of the same content.
+func Memoize(program *vm.Program, ttl time.Duration) *vm.Program {
+	return vm.Memoize(program, ttl)
+}
+
 // Eval parses, compiles and runs given input.
 func Eval(input string, env any) (any, error) {
 	if _, ok := env.(Option); ok {
 		return nil, fmt.Errorf("misused expr.Eval: second argument (env) should be passed without expr.Env")
 	}
 	input = removeCurlyBraces(input)
-	var opts []Option
-	for name, handler := range customFunctions.funcs {
-		opts = append(opts, Function(name, handler))
-	}
-	program, err := Compile(input, opts...)
+	program, err := Compile(input, customFunctionOptions()...)
 	if err != nil {
 		return nil, err
 	}
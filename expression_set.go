@@ -0,0 +1,266 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/conf"
+	"github.com/oarkflow/expr/parser"
+	"github.com/oarkflow/expr/vm"
+	"github.com/oarkflow/expr/vm/runtime"
+)
+
+// ExpressionSet is a group of programs evaluated against the same
+// environment, like rules of a rule engine checked for every event.
+type ExpressionSet struct {
+	programs []*vm.Program
+}
+
+// NewExpressionSet compiles every input with the same options.
+func NewExpressionSet(inputs []string, ops ...Option) (*ExpressionSet, error) {
+	programs := make([]*vm.Program, len(inputs))
+	for i, input := range inputs {
+		program, err := Compile(input, ops...)
+		if err != nil {
+			return nil, fmt.Errorf("expression %d: %w", i, err)
+		}
+		programs[i] = program
+	}
+	return &ExpressionSet{programs: programs}, nil
+}
+
+// Programs returns compiled programs of the set.
+func (s *ExpressionSet) Programs() []*vm.Program {
+	return s.programs
+}
+
+// RunAll evaluates all programs in parallel. Results and errors are
+// in order of programs.
+func (s *ExpressionSet) RunAll(env any) ([]any, []error) {
+	results := make([]any, len(s.programs))
+	errs := make([]error, len(s.programs))
+	var wg sync.WaitGroup
+	wg.Add(len(s.programs))
+	for i, program := range s.programs {
+		go func(i int, program *vm.Program) {
+			defer wg.Done()
+			results[i], errs[i] = Run(program, env)
+		}(i, program)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// RunUntilTrue evaluates programs in order and returns index and result
+// of the first one returning true, or -1 if none does. Evaluation stops
+// at the first error.
+func (s *ExpressionSet) RunUntilTrue(env any) (int, any, error) {
+	for i, program := range s.programs {
+		out, err := Run(program, env)
+		if err != nil {
+			return i, nil, err
+		}
+		if out == true {
+			return i, out, nil
+		}
+	}
+	return -1, nil, nil
+}
+
+// EvalMany runs programs with env concurrently, using number of workers
+// set by Parallelism option. Results are in order of programs; results of
+// failed programs are nil. Error is the one of the first failed program,
+// other programs are evaluated regardless.
+func EvalMany(programs []*vm.Program, env any, ops ...Option) ([]any, error) {
+	config := conf.CreateNew()
+	for _, op := range ops {
+		op(config)
+	}
+	workers := config.Parallelism
+	if workers <= 0 {
+		workers = goruntime.GOMAXPROCS(0)
+	}
+	if workers > len(programs) {
+		workers = len(programs)
+	}
+
+	results := make([]any, len(programs))
+	errs := make([]error, len(programs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = Run(programs[i], env)
+			}
+		}()
+	}
+	for i := range programs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// ExpressionGroup is a group of expressions sharing let bindings of
+// Shared, like "let total = price * qty; let taxed = total * 1.2;".
+// Shared bindings are evaluated once per Run and are visible to all
+// Exprs. Group is compiled without options on first Run, unless created
+// by NewExpressionGroup.
+type ExpressionGroup struct {
+	Shared string
+	Exprs  []string
+
+	once     sync.Once
+	err      error
+	shared   *vm.Program // evaluates to map of shared bindings
+	programs []*vm.Program
+}
+
+// NewExpressionGroup compiles shared bindings and expressions with ops.
+func NewExpressionGroup(shared string, exprs []string, ops ...Option) (*ExpressionGroup, error) {
+	g := &ExpressionGroup{Shared: shared, Exprs: exprs}
+	g.once.Do(func() {
+		g.err = g.compile(ops)
+	})
+	return g, g.err
+}
+
+func (g *ExpressionGroup) compile(ops []Option) error {
+	config := newConfig(ops)
+	tree, err := parser.ParsePrelude(g.Shared, config)
+	if err != nil {
+		return fmt.Errorf("shared: %w", err)
+	}
+
+	// Shared part returns map of its bindings, which replaces expression
+	// of the last declaration.
+	bindings := &ast.MapNode{}
+	values := make(map[string]ast.Node) // identifiers of bindings, typed by checker
+	last := &tree.Node
+	for *last != nil {
+		let := (*last).(*ast.VariableDeclaratorNode)
+		last = &let.Expr
+		if strings.HasPrefix(let.Name, "$") {
+			continue // hidden variable of destructuring
+		}
+		key := &ast.StringNode{Value: let.Name}
+		key.SetLocation(let.Location())
+		value := &ast.IdentifierNode{Value: let.Name}
+		value.SetLocation(let.Location())
+		pair := &ast.PairNode{Key: key, Value: value}
+		pair.SetLocation(let.Location())
+		bindings.Pairs = append(bindings.Pairs, pair)
+		values[let.Name] = value
+	}
+	*last = bindings
+	if config.PreludeExpr != "" {
+		prelude, err := parser.ParsePrelude(config.PreludeExpr, config)
+		if err != nil {
+			return fmt.Errorf("prelude: %w", err)
+		}
+		if prelude.Node != nil {
+			last := prelude.Node.(*ast.VariableDeclaratorNode)
+			for last.Expr != nil {
+				last = last.Expr.(*ast.VariableDeclaratorNode)
+			}
+			last.Expr = tree.Node
+			tree.Node = prelude.Node
+		}
+	}
+	if err := checkTree(tree, config); err != nil {
+		return fmt.Errorf("shared: %w", err)
+	}
+	g.shared, err = compileTree(tree, config)
+	if err != nil {
+		return fmt.Errorf("shared: %w", err)
+	}
+
+	types := make(map[string]reflect.Type)
+	for name, value := range values {
+		types[name] = value.Type()
+	}
+	ops = append(ops, func(c *conf.Config) {
+		if c.Types == nil {
+			c.Types = make(conf.TypesTable)
+		}
+		for name, t := range types {
+			if t == nil {
+				t = reflect.TypeOf(new(any)).Elem()
+			}
+			c.Types[name] = conf.Tag{Type: t}
+		}
+	})
+	g.programs = make([]*vm.Program, len(g.Exprs))
+	for i, input := range g.Exprs {
+		g.programs[i], err = Compile(input, ops...)
+		if err != nil {
+			return fmt.Errorf("expression %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Run evaluates shared bindings with env, then all expressions with env
+// extended with the bindings. Env should be a map or a Scope. Results
+// are in order of Exprs; evaluation stops at the first error.
+func (g *ExpressionGroup) Run(env any) ([]any, error) {
+	g.once.Do(func() {
+		g.err = g.compile(nil)
+	})
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	out, err := Run(g.shared, env)
+	if err != nil {
+		return nil, fmt.Errorf("shared: %w", err)
+	}
+	var shared map[string]any
+	switch m := out.(type) {
+	case map[string]any:
+		shared = m
+	case *runtime.OrderedMap: // compiled with OrderedMaps
+		shared = m.Map()
+	}
+	var extended any
+	switch e := env.(type) {
+	case nil:
+		extended = shared
+	case map[string]any:
+		m := make(map[string]any, len(e)+len(shared))
+		for k, v := range e {
+			m[k] = v
+		}
+		for k, v := range shared {
+			m[k] = v
+		}
+		extended = m
+	case *Scope:
+		extended = NewScope(e, shared)
+	default:
+		return nil, fmt.Errorf("expression group requires map or scope environment (got %T)", env)
+	}
+
+	results := make([]any, len(g.programs))
+	for i, program := range g.programs {
+		results[i], err = Run(program, extended)
+		if err != nil {
+			return results, fmt.Errorf("expression %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
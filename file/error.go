@@ -11,6 +11,10 @@ type Error struct {
 	Message string
 	Snippet string
 	Prev    error
+
+	// LocationPrefix formats error as "line 3, col 7: message"
+	// instead of "message (3:7)".
+	LocationPrefix bool
 }
 
 func (e *Error) Error() string {
@@ -57,6 +61,9 @@ func (e *Error) format() string {
 	if e.Location.Empty() {
 		return e.Message
 	}
+	if e.LocationPrefix {
+		return fmt.Sprintf("%v: %s%s", e.Location, e.Message, e.Snippet)
+	}
 	return fmt.Sprintf(
 		"%s (%d:%d)%s",
 		e.Message,
@@ -1,5 +1,10 @@
 package file
 
+import (
+	"fmt"
+	"sort"
+)
+
 type Location struct {
 	Line   int // The 1-based line of the location.
 	Column int // The 0-based column number of the location.
@@ -8,3 +13,20 @@ type Location struct {
 func (l Location) Empty() bool {
 	return l.Column == 0 && l.Line == 0
 }
+
+// String formats location as "line 3, col 7", with 1-based column.
+func (l Location) String() string {
+	return fmt.Sprintf("line %d, col %d", l.Line, l.Column+1)
+}
+
+// Location returns location of rune at 0-based offset in source.
+func (s *Source) Location(offset int) Location {
+	line := sort.Search(len(s.lineOffsets), func(i int) bool {
+		return int(s.lineOffsets[i]) > offset
+	})
+	start, _ := s.findLineOffset(line + 1)
+	if start < 0 {
+		start = 0
+	}
+	return Location{Line: line + 1, Column: offset - int(start)}
+}
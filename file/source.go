@@ -51,6 +51,28 @@ func (s *Source) Snippet(line int) (string, bool) {
 	return string(s.contents[charStart:]), true
 }
 
+// Lines returns lines of source.
+func (s *Source) Lines() []string {
+	return strings.Split(string(s.contents), "\n")
+}
+
+// Line returns source line by 1-based number, or empty string if line
+// doesn't exist.
+func (s *Source) Line(n int) string {
+	line, _ := s.Snippet(n)
+	return line
+}
+
+// Column returns 0-based column of loc. Locations already store column
+// in runes, so it is only bounded by length of the line.
+func (s *Source) Column(loc Location) int {
+	line := []rune(s.Line(loc.Line))
+	if loc.Column > len(line) {
+		return len(line)
+	}
+	return loc.Column
+}
+
 // updateOffsets compute line offsets up front as they are referred to frequently.
 func (s *Source) updateOffsets() {
 	lines := strings.Split(string(s.contents), "\n")
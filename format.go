@@ -0,0 +1,207 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/parser"
+	"github.com/oarkflow/expr/parser/lexer"
+)
+
+// FormatOptions customize style of Format.
+type FormatOptions struct {
+	SpaceAroundOps bool // put spaces around operators, like a + b
+	MaxLineWidth   int  // split top level && and || chains longer than this, no limit if zero
+}
+
+// DefaultFormatOptions are used by Format called without options.
+var DefaultFormatOptions = FormatOptions{SpaceAroundOps: true}
+
+// Format parses src and prints it in canonical form. Formatting is
+// idempotent: formatting of formatted expression does not change it.
+func Format(src string, opts ...FormatOptions) (string, error) {
+	options := DefaultFormatOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	want := ast.Dump(tree.Node)
+	tree.Node = resugar(tree.Node)
+	line, err := formatNode(tree.Node, options)
+	if err != nil {
+		return "", err
+	}
+	if err := checkRoundTrip(line, want); err != nil {
+		return "", err
+	}
+	if options.MaxLineWidth <= 0 || len(line) <= options.MaxLineWidth {
+		return line, nil
+	}
+	b, ok := tree.Node.(*ast.BinaryNode)
+	if !ok || !isLogical(b.Operator) {
+		return line, nil
+	}
+	operands := logicalOperands(b, b.Operator)
+	lines := make([]string, len(operands))
+	for i, operand := range operands {
+		s, err := formatNode(operand, options)
+		if err != nil {
+			return "", err
+		}
+		if o, ok := operand.(*ast.BinaryNode); ok && isLogical(o.Operator) {
+			s = "(" + s + ")"
+		}
+		lines[i] = s
+	}
+	return strings.Join(lines, " "+b.Operator+"\n\t"), nil
+}
+
+// checkRoundTrip reports an error if formatted expression is not parsed
+// into a tree with dump want, so formatting never changes meaning.
+func checkRoundTrip(formatted, want string) error {
+	tree, err := parser.Parse(formatted)
+	if err != nil {
+		return fmt.Errorf("formatted expression %q is invalid: %w", formatted, err)
+	}
+	if ast.Dump(tree.Node) != want {
+		return fmt.Errorf("formatted expression %q changes meaning of source", formatted)
+	}
+	return nil
+}
+
+// resugar replaces let declarations produced by parser for switch back
+// with switchNode, so they are printed as written.
+func resugar(node ast.Node) ast.Node {
+	return ast.Rewrite(node, func(n ast.Node) (ast.Node, bool) {
+		if let, ok := n.(*ast.VariableDeclaratorNode); ok && strings.HasPrefix(let.Name, "$switch") {
+			return &switchNode{let}, true
+		}
+		return nil, false
+	})
+}
+
+// switchNode prints `let $switchN = subject; $switchN == a ? x : ...`
+// as `switch (subject) { case a: x, ..., default: z }`.
+type switchNode struct {
+	*ast.VariableDeclaratorNode
+}
+
+func (n *switchNode) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "switch (%s) { ", n.Value.String())
+	node := n.Expr
+	for {
+		c, ok := node.(*ast.ConditionalNode)
+		if !ok {
+			break
+		}
+		b, ok := c.Cond.(*ast.BinaryNode)
+		if !ok || b.Operator != "==" {
+			break
+		}
+		if id, ok := b.Left.(*ast.IdentifierNode); !ok || id.Value != n.Name {
+			break
+		}
+		value := b.Right.String()
+		if _, ok := b.Right.(*ast.ConditionalNode); ok {
+			value = "(" + value + ")"
+		}
+		fmt.Fprintf(&sb, "case %s: %s, ", value, c.Exp1.String())
+		node = c.Exp2
+	}
+	fmt.Fprintf(&sb, "default: %s }", node.String())
+	return sb.String()
+}
+
+func isLogical(op string) bool {
+	return op == "&&" || op == "||" || op == "and" || op == "or"
+}
+
+// logicalOperands flattens chain of op into its operands.
+func logicalOperands(node ast.Node, op string) []ast.Node {
+	if b, ok := node.(*ast.BinaryNode); ok && b.Operator == op {
+		return append(logicalOperands(b.Left, op), logicalOperands(b.Right, op)...)
+	}
+	return []ast.Node{node}
+}
+
+// formatNode prints node, removing spaces around operators unless
+// options.SpaceAroundOps is set.
+func formatNode(node ast.Node, options FormatOptions) (string, error) {
+	s := node.String()
+	if options.SpaceAroundOps {
+		return s, nil
+	}
+	tokens, err := lexer.Tokens(s)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	var prev lexer.Token
+	for i, t := range tokens {
+		if t.Kind == lexer.EOF {
+			break
+		}
+		if i > 0 && needsSpace(prev, t) {
+			sb.WriteByte(' ')
+		}
+		if t.Raw != "" {
+			sb.WriteString(t.Raw)
+		} else {
+			sb.WriteString(t.Value)
+		}
+		prev = t
+	}
+	return sb.String(), nil
+}
+
+// needsSpace reports whether tokens a and b must be separated to be
+// lexed the same way again.
+func needsSpace(a, b lexer.Token) bool {
+	switch {
+	case a.Is(lexer.Operator, ","):
+		return true
+	case isWord(a) && isWord(b):
+		return true
+	case a.Kind == lexer.Operator && b.Kind == lexer.Operator:
+		return true
+	case a.Kind == lexer.Operator && isWord(a), b.Kind == lexer.Operator && isWord(b):
+		return true
+	}
+	return false
+}
+
+func isWord(t lexer.Token) bool {
+	switch t.Kind {
+	case lexer.Identifier, lexer.Number:
+		return true
+	case lexer.Operator:
+		return t.Value != "" && unicode.IsLetter(rune(t.Value[0]))
+	}
+	return false
+}
+
+// Rewrite parses src, rewrites it with ast.Rewrite, calling fn for
+// children before parents, and returns formatted result. It helps to
+// migrate stored expressions, like renaming a deprecated function.
+func Rewrite(src string, fn func(ast.Node) (ast.Node, bool)) (string, error) {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	node := ast.Rewrite(tree.Node, fn)
+	want := ast.Dump(node)
+	out, err := formatNode(resugar(node), DefaultFormatOptions)
+	if err != nil {
+		return "", err
+	}
+	if err := checkRoundTrip(out, want); err != nil {
+		return "", err
+	}
+	return out, nil
+}
@@ -0,0 +1,90 @@
+package expr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/expr"
+	"github.com/oarkflow/expr/vm"
+)
+
+func TestFunc(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{`let fib = func(n) { n <= 1 ? n : fib(n - 1) + fib(n - 2) }; fib(10)`, 55},
+		{`let fact = func(n) { n == 0 ? 1 : n * fact(n - 1) }; fact(5)`, 120},
+		{`let k = 10; let add = func(x) { x + k }; add(5)`, 15},
+		{`let add = func(a, b) { a + b }; let inc = add(1); inc(2)`, 3},
+		{`let make = func(n) { func(x) { x * n } }; let triple = make(3); triple(4)`, 12},
+		{`map([1, 2], { let f = func(y) { y + # }; f(10) })`, []any{11, 12}},
+		{`let fs = map([1, 2], { [func(y) { y + # }] }); map(fs, #[0](10))`, []any{11, 12}},
+		{`let add = func(a, b) { a + b }; map([1, 2], add(10))`, []any{11, 12}},
+		{`let f = func(n) { n * 2 }; map([1, 2], f)`, []any{2, 4}},
+		{`[1, 2] | map(func(x) { x * 2 })`, []any{2, 4}},
+		{`filter([1, 2, 3], func(x) { x > 1 })`, []any{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := expr.Eval(tt.input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equal(out, tt.want) {
+				t.Errorf("got %v, want %v", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunc_errors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`let add = func(a, b) { a + b }; add(1, 2, 3)`, "too many arguments to call add"},
+		{`let add = func(a, b) { a + b }; let g = [add][0]; g(1, 2, 3)`, "add expects 2 arguments (got 3)"},
+		{`let loop = func(n) { loop(n + 1) + 1 }; loop(0)`, "maximum call depth exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := expr.Eval(tt.input, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFunc_MaxCallDepth(t *testing.T) {
+	defer func(depth int) { vm.MaxCallDepth = depth }(vm.MaxCallDepth)
+	vm.MaxCallDepth = 10
+	program, err := expr.Compile(`let down = func(n) { n == 0 ? 0 : 1 + down(n - 1) }; down(depth)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := expr.Run(program, map[string]any{"depth": 5}); err != nil || out != 5 {
+		t.Errorf("depth 5: got %v, %v", out, err)
+	}
+	if _, err := expr.Run(program, map[string]any{"depth": 20}); err == nil || !strings.Contains(err.Error(), "maximum call depth exceeded") {
+		t.Errorf("depth 20: got error %v", err)
+	}
+}
+
+func equal(a, b any) bool {
+	x, ok := a.([]any)
+	y, ok2 := b.([]any)
+	if !ok || !ok2 {
+		return a == b
+	}
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
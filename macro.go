@@ -0,0 +1,59 @@
+package expr
+
+import (
+	"sync"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/file"
+)
+
+// Macro transforms arguments of a call, unevaluated, into a node which
+// replaces the call.
+type Macro func(args []ast.Node) (ast.Node, error)
+
+var macros = struct {
+	mu     sync.RWMutex
+	macros map[string]Macro
+}{macros: make(map[string]Macro)}
+
+// AddMacro registers macro name, expanded in all compiled expressions.
+// Calls like name(a, b) are replaced with result of transform called
+// with nodes a and b before type checking and optimization. Arguments
+// are expanded before macro receives them. Names of builtins cannot be
+// used as macros.
+func AddMacro(name string, transform func(args []ast.Node) (ast.Node, error)) {
+	macros.mu.Lock()
+	defer macros.mu.Unlock()
+	macros.macros[name] = transform
+}
+
+// macroExpansion replaces calls of registered macros in node.
+func macroExpansion(node *ast.Node) error {
+	macros.mu.RLock()
+	defer macros.mu.RUnlock()
+	if len(macros.macros) == 0 {
+		return nil
+	}
+	var err error
+	*node = ast.Rewrite(*node, func(n ast.Node) (ast.Node, bool) {
+		call, ok := n.(*ast.CallNode)
+		if !ok || err != nil {
+			return n, false
+		}
+		callee, ok := call.Callee.(*ast.IdentifierNode)
+		if !ok {
+			return n, false
+		}
+		transform, ok := macros.macros[callee.Value]
+		if !ok {
+			return n, false
+		}
+		replacement, e := transform(call.Arguments)
+		if e != nil {
+			err = &file.Error{Location: call.Location(), Message: e.Error()}
+			return n, false
+		}
+		return replacement, true
+	})
+	return err
+}
@@ -0,0 +1,101 @@
+package optimizer
+
+import (
+	. "github.com/oarkflow/expr/ast"
+)
+
+// constBlock replaces variables of do blocks bound to literals with
+// the literals themselves, so they can be folded further.
+type constBlock struct{}
+
+func (*constBlock) Visit(node *Node) {
+	block, ok := (*node).(*BlockNode)
+	if !ok {
+		return
+	}
+	statements := make([]Node, 0, len(block.Statements))
+	for i, stmt := range block.Statements {
+		let, ok := stmt.(*VariableDeclaratorNode)
+		if !ok || let.Expr != nil || !isLiteral(let.Value) {
+			statements = append(statements, stmt)
+			continue
+		}
+		rest := append(append([]Node{}, block.Statements[i+1:]...), block.Result)
+		if declares(rest, let.Name) {
+			statements = append(statements, stmt)
+			continue
+		}
+		r := &replaceIdentifier{name: let.Name, value: let.Value}
+		for j := i + 1; j < len(block.Statements); j++ {
+			Walk(&block.Statements[j], r)
+		}
+		Walk(&block.Result, r)
+	}
+	block.Statements = statements
+}
+
+func isLiteral(node Node) bool {
+	switch node.(type) {
+	case *NilNode, *BoolNode, *IntegerNode, *FloatNode, *StringNode:
+		return true
+	}
+	return false
+}
+
+func copyLiteral(node Node) Node {
+	switch n := node.(type) {
+	case *BoolNode:
+		return &BoolNode{Value: n.Value}
+	case *IntegerNode:
+		return &IntegerNode{Value: n.Value}
+	case *FloatNode:
+		return &FloatNode{Value: n.Value}
+	case *StringNode:
+		return &StringNode{Value: n.Value}
+	}
+	return &NilNode{}
+}
+
+type replaceIdentifier struct {
+	name  string
+	value Node
+}
+
+func (r *replaceIdentifier) Visit(node *Node) {
+	if id, ok := (*node).(*IdentifierNode); ok && id.Value == r.name {
+		Patch(node, copyLiteral(r.value))
+	}
+}
+
+// declares reports whether name is declared again somewhere in nodes,
+// as a parameter or a variable, and shadows the outer one.
+func declares(nodes []Node, name string) bool {
+	f := &declarationFinder{name: name}
+	for i := range nodes {
+		Walk(&nodes[i], f)
+	}
+	return f.found
+}
+
+type declarationFinder struct {
+	name  string
+	found bool
+}
+
+func (f *declarationFinder) Visit(node *Node) {
+	switch n := (*node).(type) {
+	case *VariableDeclaratorNode:
+		if n.Name == f.name {
+			f.found = true
+		}
+	case *FunctionNode:
+		if n.Name == f.name {
+			f.found = true
+		}
+		for _, param := range n.Params {
+			if param == f.name {
+				f.found = true
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package optimizer
+
+import (
+	"reflect"
+
+	. "github.com/oarkflow/expr/ast"
+)
+
+// demorgan pushes negations inward: not (a and b) becomes (not a) or
+// (not b), not (a == b) becomes a != b and double negations are removed.
+type demorgan struct{}
+
+func (*demorgan) Visit(node *Node) {
+	if n, ok := (*node).(*UnaryNode); ok && isNot(n.Operator) {
+		if negated, ok := negate(n.Node, n.Operator); ok {
+			Patch(node, negated)
+		}
+	}
+}
+
+func isNot(operator string) bool {
+	return operator == "not" || operator == "!"
+}
+
+// negate returns negation of node without not wrapping it, or false if
+// node cannot be negated this way. Operator is spelling of not to use.
+func negate(node Node, operator string) (Node, bool) {
+	var negated Node
+	switch n := node.(type) {
+	case *UnaryNode:
+		if !isNot(n.Operator) {
+			return nil, false
+		}
+		return n.Node, true
+	case *BoolNode:
+		negated = &BoolNode{Value: !n.Value}
+	case *BinaryNode:
+		opposite, ok := map[string]string{
+			"and": "or",
+			"&&":  "||",
+			"or":  "and",
+			"||":  "&&",
+			"==":  "!=",
+			"!=":  "==",
+		}[n.Operator]
+		if !ok {
+			return nil, false
+		}
+		left, right := n.Left, n.Right
+		if n.Operator != "==" && n.Operator != "!=" {
+			left = negateOrWrap(left, operator)
+			right = negateOrWrap(right, operator)
+		}
+		negated = &BinaryNode{Operator: opposite, Left: left, Right: right}
+	default:
+		return nil, false
+	}
+	negated.SetLocation(node.Location())
+	negated.SetType(reflect.TypeOf(true))
+	return negated, true
+}
+
+func negateOrWrap(node Node, operator string) Node {
+	if negated, ok := negate(node, operator); ok {
+		return negated
+	}
+	not := &UnaryNode{Operator: operator, Node: node}
+	not.SetLocation(node.Location())
+	not.SetType(reflect.TypeOf(true))
+	return not
+}
@@ -35,4 +35,19 @@ func (*filterFirst) Visit(node *Node) {
 			})
 		}
 	}
+	if coalesce, ok := (*node).(*BinaryNode); ok && coalesce.Operator == "??" {
+		// first(filter(a, p)) ?? d, patched above to find(a, p) ?? d,
+		// becomes find(a, p, d).
+		// Note that found nil element is returned as is, not replaced by d.
+		if find, ok := coalesce.Left.(*BuiltinNode); ok &&
+			find.Name == "find" &&
+			len(find.Arguments) == 2 &&
+			!find.Throws {
+			Patch(node, &BuiltinNode{
+				Name:      "find",
+				Arguments: []Node{find.Arguments[0], find.Arguments[1], coalesce.Right},
+				Map:       find.Map,
+			})
+		}
+	}
 }
@@ -35,4 +35,19 @@ func (*filterLast) Visit(node *Node) {
 			})
 		}
 	}
+	if coalesce, ok := (*node).(*BinaryNode); ok && coalesce.Operator == "??" {
+		// last(filter(a, p)) ?? d, patched above to findLast(a, p) ?? d,
+		// becomes findLast(a, p, d).
+		// Note that found nil element is returned as is, not replaced by d.
+		if find, ok := coalesce.Left.(*BuiltinNode); ok &&
+			find.Name == "findLast" &&
+			len(find.Arguments) == 2 &&
+			!find.Throws {
+			Patch(node, &BuiltinNode{
+				Name:      "findLast",
+				Arguments: []Node{find.Arguments[0], find.Arguments[1], coalesce.Right},
+				Map:       find.Map,
+			})
+		}
+	}
 }
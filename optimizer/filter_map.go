@@ -13,7 +13,8 @@ func (*filterMap) Visit(node *Node) {
 		if closure, ok := mapBuiltin.Arguments[1].(*ClosureNode); ok {
 			if filter, ok := mapBuiltin.Arguments[0].(*BuiltinNode); ok &&
 				filter.Name == "filter" &&
-				filter.Map == nil /* not already optimized */ {
+				filter.Map == nil /* not already optimized */ &&
+				!usesIndex(closure.Node) {
 				Patch(node, &BuiltinNode{
 					Name:      "filter",
 					Arguments: filter.Arguments,
@@ -22,4 +23,92 @@ func (*filterMap) Visit(node *Node) {
 			}
 		}
 	}
+
+	// filter(map(arr, .Name), # != "") is rewritten to filter(arr, .Name != "")
+	// mapping matching elements with .Name, without an intermediate array.
+	// Transform is evaluated twice for matching elements, so only cheap
+	// transforms are inlined into predicate.
+	if filter, ok := (*node).(*BuiltinNode); ok &&
+		filter.Name == "filter" &&
+		filter.Map == nil &&
+		len(filter.Arguments) == 2 {
+		predicate, ok := filter.Arguments[1].(*ClosureNode)
+		if !ok || hasClosure(predicate.Node) {
+			return
+		}
+		if mapBuiltin, ok := filter.Arguments[0].(*BuiltinNode); ok &&
+			mapBuiltin.Name == "map" &&
+			len(mapBuiltin.Arguments) == 2 {
+			if transform, ok := mapBuiltin.Arguments[1].(*ClosureNode); ok && isCheap(transform.Node) {
+				Walk(&predicate.Node, &pointerReplacer{with: transform.Node})
+				Patch(node, &BuiltinNode{
+					Name:      "filter",
+					Arguments: []Node{mapBuiltin.Arguments[0], predicate},
+					Map:       transform.Node,
+				})
+			}
+		}
+	}
+}
+
+// isCheap reports whether node is a member access on the current element,
+// like # or #.User.Name.
+func isCheap(node Node) bool {
+	switch n := node.(type) {
+	case *PointerNode:
+		return n.Name == ""
+	case *MemberNode:
+		switch n.Property.(type) {
+		case *StringNode, *IntegerNode:
+			return isCheap(n.Node)
+		}
+	case *ChainNode:
+		return isCheap(n.Node)
+	}
+	return false
+}
+
+func hasClosure(node Node) bool {
+	v := &closureFinder{}
+	Walk(&node, v)
+	return v.found
+}
+
+type closureFinder struct {
+	found bool
+}
+
+func (v *closureFinder) Visit(node *Node) {
+	if _, ok := (*node).(*ClosureNode); ok {
+		v.found = true
+	}
+}
+
+// pointerReplacer replaces current element # with node.
+type pointerReplacer struct {
+	with Node
+}
+
+func (v *pointerReplacer) Visit(node *Node) {
+	if p, ok := (*node).(*PointerNode); ok && p.Name == "" {
+		*node = v.with
+	}
+}
+
+// usesIndex reports whether node refers to #index, which differs
+// between filter and map and prevents fusing them into one loop.
+func usesIndex(node Node) bool {
+	v := &indexFinder{}
+	Walk(&node, v)
+	return v.found
+}
+
+type indexFinder struct {
+	found bool
+}
+
+func (v *indexFinder) Visit(node *Node) {
+	if p, ok := (*node).(*PointerNode); ok && p.Name == "index" {
+		v.found = true
+	}
 }
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/builtin"
 	"github.com/oarkflow/expr/file"
+	"github.com/oarkflow/expr/vm/runtime"
 )
 
 var (
@@ -16,8 +20,9 @@ var (
 )
 
 type fold struct {
-	applied bool
-	err     *file.Error
+	applied      bool
+	err          *file.Error
+	strictSemVer bool
 }
 
 func (fold *fold) Visit(node *ast.Node) {
@@ -273,6 +278,27 @@ func (fold *fold) Visit(node *ast.Node) {
 					patch(&ast.BoolNode{Value: a.Value == b.Value})
 				}
 			}
+		case "equalsIgnoreCase":
+			a := toString(n.Left)
+			b := toString(n.Right)
+			if a != nil && b != nil {
+				patch(&ast.BoolNode{Value: strings.EqualFold(a.Value, b.Value)})
+			}
+		case "semverEq", "semverGt", "semverGte", "semverLt", "semverLte":
+			a := toString(n.Left)
+			b := toString(n.Right)
+			if a != nil && b != nil {
+				defer func() {
+					if r := recover(); r != nil {
+						fold.err = &file.Error{
+							Location: (*node).Location(),
+							Message:  fmt.Sprintf("%v", r),
+						}
+					}
+				}()
+				op := slices.Index(runtime.SemVerOperators, n.Operator)
+				patch(&ast.BoolNode{Value: runtime.SemVerOp(op, a.Value, b.Value, fold.strictSemVer)})
+			}
 		}
 
 	case *ast.ArrayNode:
@@ -302,6 +328,18 @@ func (fold *fold) Visit(node *ast.Node) {
 		}
 
 	case *ast.BuiltinNode:
+		if fn, ok := builtin.Index[n.Name]; ok && n.Pure {
+			if value, ok, err := callPure(builtin.Builtins[fn], n.Arguments); err != nil {
+				fold.err = &file.Error{
+					Location: (*node).Location(),
+					Message:  err.Error(),
+				}
+				return
+			} else if ok {
+				patch(toLiteral(value))
+				return
+			}
+		}
 		switch n.Name {
 		case "filter":
 			if len(n.Arguments) != 2 {
@@ -321,6 +359,17 @@ func (fold *fold) Visit(node *ast.Node) {
 				})
 			}
 		}
+
+	case *ast.ConditionalNode:
+		if cond, ok := n.Cond.(*ast.BoolNode); ok {
+			// Keep type of the chosen branch, it is more precise.
+			fold.applied = true
+			if cond.Value {
+				*node = n.Exp1
+			} else {
+				*node = n.Exp2
+			}
+		}
 	}
 }
 
@@ -355,3 +404,55 @@ func toBool(n ast.Node) *ast.BoolNode {
 	}
 	return nil
 }
+
+// callPure calls pure builtin if all arguments are literals.
+func callPure(fn *ast.Function, arguments []ast.Node) (value any, ok bool, err error) {
+	args := make([]any, len(arguments))
+	for i, arg := range arguments {
+		switch a := arg.(type) {
+		case *ast.NilNode:
+			args[i] = nil
+		case *ast.IntegerNode:
+			args[i] = a.Value
+		case *ast.FloatNode:
+			args[i] = a.Value
+		case *ast.BoolNode:
+			args[i] = a.Value
+		case *ast.StringNode:
+			args[i] = a.Value
+		case *ast.ConstantNode:
+			args[i] = a.Value
+		default:
+			return nil, false, nil
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if fn.Fast != nil && len(args) == 1 {
+		return fn.Fast(args[0]), true, nil
+	}
+	if fn.Func == nil {
+		return nil, false, nil
+	}
+	value, err = fn.Func(args...)
+	return value, err == nil, err
+}
+
+func toLiteral(value any) ast.Node {
+	switch v := value.(type) {
+	case nil:
+		return &ast.NilNode{}
+	case int:
+		return &ast.IntegerNode{Value: v}
+	case float64:
+		return &ast.FloatNode{Value: v}
+	case bool:
+		return &ast.BoolNode{Value: v}
+	case string:
+		return &ast.StringNode{Value: v}
+	}
+	return &ast.ConstantNode{Value: value}
+}
@@ -11,7 +11,7 @@ type inArray struct{}
 func (*inArray) Visit(node *Node) {
 	switch n := (*node).(type) {
 	case *BinaryNode:
-		if n.Operator == "in" {
+		if n.Operator == "in" || n.Operator == "not in" {
 			if array, ok := n.Right.(*ArrayNode); ok {
 				if len(array.Nodes) > 0 {
 					t := n.Left.Type()
@@ -35,7 +35,7 @@ func (*inArray) Visit(node *Node) {
 						Patch(node, &BinaryNode{
 							Operator: n.Operator,
 							Left:     n.Left,
-							Right:    &ConstantNode{Value: value},
+							Right:    &ConstantNode{Value: value, OriginalExpr: array.String()},
 						})
 					}
 
@@ -53,7 +53,7 @@ func (*inArray) Visit(node *Node) {
 						Patch(node, &BinaryNode{
 							Operator: n.Operator,
 							Left:     n.Left,
-							Right:    &ConstantNode{Value: value},
+							Right:    &ConstantNode{Value: value, OriginalExpr: array.String()},
 						})
 					}
 
@@ -0,0 +1,43 @@
+package optimizer
+
+import (
+	"reflect"
+
+	. "github.com/oarkflow/expr/ast"
+)
+
+type inMap struct{}
+
+func (*inMap) Visit(node *Node) {
+	switch n := (*node).(type) {
+	case *BinaryNode:
+		if n.Operator == "in" || n.Operator == "not in" {
+			constant, ok := n.Right.(*ConstantNode)
+			if !ok {
+				return
+			}
+			m := reflect.ValueOf(constant.Value)
+			if m.Kind() != reflect.Map {
+				return
+			}
+			var key any
+			switch left := n.Left.(type) {
+			case *StringNode:
+				key = left.Value
+			case *IntegerNode:
+				key = left.Value
+			case *BoolNode:
+				key = left.Value
+			default:
+				return
+			}
+			k := reflect.ValueOf(key)
+			if !k.Type().AssignableTo(m.Type().Key()) {
+				return
+			}
+			found := m.MapIndex(k).IsValid()
+			Patch(node, &BoolNode{Value: found == (n.Operator == "in")})
+			(*node).SetType(reflect.TypeOf(true))
+		}
+	}
+}
@@ -1,15 +1,66 @@
 package optimizer
 
 import (
+	"fmt"
+	"reflect"
+
 	ast2 "github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/conf"
+	"github.com/oarkflow/expr/file"
 )
 
-func Optimize(node *ast2.Node, config *conf.Config) error {
-	ast2.Walk(node, &inArray{})
+// Step is a single transformation made by an optimizer pass.
+type Step struct {
+	Pass     string
+	Before   string
+	After    string
+	Type     string        // type of replacement node, like "IntegerNode"
+	Location file.Location // location of replaced node
+}
+
+// OptimizeResult describes work done by Optimize.
+type OptimizeResult struct {
+	PassesFired []string       // passes which replaced nodes, in order of first replacement
+	Iterations  map[string]int // number of runs of every pass over the tree
+	NodesBefore int            // number of nodes before optimization
+	NodesAfter  int            // number of nodes after optimization
+}
+
+func Optimize(node *ast2.Node, config *conf.Config) (OptimizeResult, error) {
+	o := &optimizer{}
+	o.result.NodesBefore = countNodes(*node)
+	err := o.optimize(node, config)
+	o.result.NodesAfter = countNodes(*node)
+	return o.result, err
+}
+
+// Trace is Optimize which returns transformations made by optimizer passes.
+func Trace(node *ast2.Node, config *conf.Config) ([]Step, error) {
+	o := &optimizer{trace: true}
+	err := o.optimize(node, config)
+	return o.steps, err
+}
+
+type optimizer struct {
+	trace  bool
+	steps  []Step
+	result OptimizeResult
+}
+
+func (o *optimizer) optimize(node *ast2.Node, config *conf.Config) error {
+	if config != nil && config.DisableOptimizer {
+		if config.Verbose != nil {
+			_, _ = fmt.Fprintln(config.Verbose, "optimizer is disabled, expressions may run slower")
+		}
+		return nil
+	}
+	o.walk(node, "inArray", &inArray{})
+	o.walk(node, "demorgan", &demorgan{})
+	o.walk(node, "predicateLiteral", &predicateLiteral{})
 	for limit := 1000; limit >= 0; limit-- {
-		fold := &fold{}
-		ast2.Walk(node, fold)
+		o.walk(node, "constBlock", &constBlock{})
+		fold := &fold{strictSemVer: config != nil && config.StrictSemVer}
+		o.walk(node, "fold", fold)
 		if fold.err != nil {
 			return fold.err
 		}
@@ -22,7 +73,7 @@ func Optimize(node *ast2.Node, config *conf.Config) error {
 			constExpr := &constExpr{
 				fns: config.ConstFns,
 			}
-			ast2.Walk(node, constExpr)
+			o.walk(node, "constExpr", constExpr)
 			if constExpr.err != nil {
 				return constExpr.err
 			}
@@ -31,11 +82,64 @@ func Optimize(node *ast2.Node, config *conf.Config) error {
 			}
 		}
 	}
-	ast2.Walk(node, &inRange{})
-	ast2.Walk(node, &constRange{})
-	ast2.Walk(node, &filterMap{})
-	ast2.Walk(node, &filterLen{})
-	ast2.Walk(node, &filterLast{})
-	ast2.Walk(node, &filterFirst{})
+	o.walk(node, "inRange", &inRange{})
+	o.walk(node, "inMap", &inMap{})
+	o.walk(node, "constRange", &constRange{})
+	o.walk(node, "filterMap", &filterMap{})
+	o.walk(node, "filterLen", &filterLen{})
+	o.walk(node, "filterLast", &filterLast{})
+	o.walk(node, "filterFirst", &filterFirst{})
 	return nil
 }
+
+func (o *optimizer) walk(node *ast2.Node, pass string, visitor ast2.Visitor) {
+	if o.result.Iterations == nil {
+		o.result.Iterations = make(map[string]int)
+	}
+	o.result.Iterations[pass]++
+	ast2.Walk(node, &tracer{optimizer: o, pass: pass, visitor: visitor})
+}
+
+// tracer moves comments of nodes replaced by visitor to replacements,
+// and records replacements if tracing is enabled.
+type tracer struct {
+	optimizer *optimizer
+	pass      string
+	visitor   ast2.Visitor
+}
+
+func (t *tracer) Visit(node *ast2.Node) {
+	before := *node
+	t.visitor.Visit(node)
+	if *node == before {
+		return
+	}
+	ast2.KeepComment(before, *node)
+	t.optimizer.fired(t.pass)
+	if constant, ok := (*node).(*ast2.ConstantNode); ok && constant.OriginalExpr == "" {
+		constant.OriginalExpr = before.String()
+	}
+	if t.optimizer.trace {
+		t.optimizer.steps = append(t.optimizer.steps, Step{
+			Pass:     t.pass,
+			Before:   before.String(),
+			After:    (*node).String(),
+			Type:     reflect.TypeOf(*node).Elem().Name(),
+			Location: before.Location(),
+		})
+	}
+}
+
+// fired records that pass replaced a node.
+func (o *optimizer) fired(pass string) {
+	for _, p := range o.result.PassesFired {
+		if p == pass {
+			return
+		}
+	}
+	o.result.PassesFired = append(o.result.PassesFired, pass)
+}
+
+func countNodes(node ast2.Node) int {
+	return len(ast2.Find(node, func(ast2.Node) bool { return true }))
+}
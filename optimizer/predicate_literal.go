@@ -0,0 +1,72 @@
+package optimizer
+
+import (
+	"reflect"
+
+	. "github.com/oarkflow/expr/ast"
+)
+
+// predicateLiteral replaces all, any, none and one with constant
+// predicates by checks of length of the collection, like any(items, true)
+// by len(items) > 0.
+type predicateLiteral struct{}
+
+func (*predicateLiteral) Visit(node *Node) {
+	n, ok := (*node).(*BuiltinNode)
+	if !ok || len(n.Arguments) != 2 {
+		return
+	}
+	closure, ok := n.Arguments[1].(*ClosureNode)
+	if !ok {
+		return
+	}
+	predicate, ok := closure.Node.(*BoolNode)
+	if !ok {
+		return
+	}
+
+	// Result is constant, or len(items) compared with number.
+	var constant, result bool
+	var operator string
+	var number int
+	switch n.Name {
+	case "any":
+		if predicate.Value {
+			operator, number = ">", 0
+		} else {
+			constant, result = true, false
+		}
+	case "all":
+		if predicate.Value {
+			constant, result = true, true
+		} else {
+			operator, number = "==", 0
+		}
+	case "none":
+		if predicate.Value {
+			operator, number = "==", 0
+		} else {
+			constant, result = true, true
+		}
+	case "one":
+		if predicate.Value {
+			operator, number = "==", 1
+		} else {
+			constant, result = true, false
+		}
+	default:
+		return
+	}
+
+	if constant {
+		Patch(node, &BoolNode{Value: result})
+		return
+	}
+	length := &BuiltinNode{Name: "len", Arguments: []Node{n.Arguments[0]}}
+	length.SetLocation(n.Location())
+	length.SetType(reflect.TypeOf(0))
+	value := &IntegerNode{Value: number}
+	value.SetLocation(n.Location())
+	value.SetType(reflect.TypeOf(0))
+	Patch(node, &BinaryNode{Operator: operator, Left: length, Right: value})
+}
@@ -2,17 +2,41 @@ package lexer
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/oarkflow/expr/file"
 )
 
+// Tokens returns tokens of src, ending with EOF token.
+func Tokens(src string) ([]Token, error) {
+	return Lex(file.NewSource(src))
+}
+
 func Lex(source *file.Source) ([]Token, error) {
+	return LexWithLiterals(source, nil)
+}
+
+// LexWithLiterals is Lex which emits literals starting with one of
+// prefixes, like $100.50 for prefix "$" or ip"10.0.0.1" for prefix "ip",
+// as CustomLiteral tokens.
+func LexWithLiterals(source *file.Source, prefixes []string) ([]Token, error) {
+	for _, prefix := range prefixes {
+		if err := CheckLiteralPrefix(prefix); err != nil {
+			return nil, err
+		}
+	}
 	l := &lexer{
-		input:  source.Content(),
-		tokens: make([]Token, 0),
+		input:    source.Content(),
+		tokens:   make([]Token, 0),
+		literals: append([]string{}, prefixes...),
 	}
+	// Longest prefix wins.
+	sort.Slice(l.literals, func(i, j int) bool {
+		return len(l.literals[i]) > len(l.literals[j])
+	})
 
 	l.loc = file.Location{Line: 1, Column: 0}
 	l.prev = l.loc
@@ -29,6 +53,24 @@ func Lex(source *file.Source) ([]Token, error) {
 	return l.tokens, nil
 }
 
+// CheckLiteralPrefix returns an error if prefix cannot be used for custom
+// literals: it is empty, contains digits, quotes or spaces, or clashes
+// with $env.
+func CheckLiteralPrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("custom literal prefix is empty")
+	}
+	if strings.IndexFunc(prefix, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsSpace(r) || strings.ContainsRune("'\"`", r)
+	}) >= 0 {
+		return fmt.Errorf("custom literal prefix %q contains digits, quotes or spaces", prefix)
+	}
+	if prefix != "$" && (strings.HasPrefix("$env", prefix) || strings.HasPrefix(prefix, "$env")) {
+		return fmt.Errorf("custom literal prefix %q clashes with $env", prefix)
+	}
+	return nil
+}
+
 type lexer struct {
 	input      string
 	tokens     []Token
@@ -37,6 +79,7 @@ type lexer struct {
 	startLoc   file.Location // start location
 	prev, loc  file.Location // prev location of end location, end location
 	err        *file.Error
+	literals   []string // prefixes of custom literals, longest first
 }
 
 const eof rune = -1
@@ -81,6 +124,7 @@ func (l *lexer) emitValue(t Kind, value string) {
 		Location: l.startLoc,
 		Kind:     t,
 		Value:    value,
+		Raw:      l.word(),
 	})
 	l.start = l.end
 	l.startLoc = l.loc
@@ -206,7 +250,7 @@ func (l *lexer) scanEscape(quote rune) rune {
 func (l *lexer) scanString(quote rune) (n int) {
 	ch := l.next() // read character after quote
 	for ch != quote {
-		if (ch == '\n' || ch == eof) && quote != '`' {
+		if ch == eof || ch == '\n' && quote != '`' {
 			l.error("literal not terminated")
 			return
 		}
@@ -0,0 +1,40 @@
+package lexer_test
+
+import (
+	"testing"
+
+	"github.com/oarkflow/expr/parser/lexer"
+)
+
+func TestLex_unicode_identifiers(t *testing.T) {
+	tests := []string{
+		"поле",
+		"名前",
+		"αρχή",
+		"الاسم",
+		"नमस्ते",
+		"हिंदी",
+	}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			tokens, err := lexer.Tokens(name + " + 1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []lexer.Token{
+				{Kind: lexer.Identifier, Value: name},
+				{Kind: lexer.Operator, Value: "+"},
+				{Kind: lexer.Number, Value: "1"},
+				{Kind: lexer.EOF},
+			}
+			if len(tokens) != len(want) {
+				t.Fatalf("got %v, want %v", tokens, want)
+			}
+			for i := range want {
+				if tokens[i].Kind != want[i].Kind || tokens[i].Value != want[i].Value {
+					t.Fatalf("got %v, want %v", tokens, want)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package lexer
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/oarkflow/expr/parser/utils"
 )
@@ -9,6 +11,9 @@ import (
 type stateFn func(*lexer) stateFn
 
 func root(l *lexer) stateFn {
+	if prefix, ok := l.literalPrefix(); ok {
+		return customLiteral(prefix)
+	}
 	switch r := l.next(); {
 	case r == eof:
 		l.emitEOF()
@@ -33,7 +38,10 @@ func root(l *lexer) stateFn {
 	case r == '#':
 		return pointer
 	case r == '|':
-		l.accept("|")
+		// "||", "|>" or "|".
+		if !l.accept("|") {
+			l.accept(">")
+		}
 		l.emit(Operator)
 	case strings.ContainsRune("([{", r):
 		l.emit(Bracket)
@@ -42,7 +50,9 @@ func root(l *lexer) stateFn {
 	case strings.ContainsRune(",:;%+-^", r): // single rune operator
 		l.emit(Operator)
 	case strings.ContainsRune("&!=*<>", r): // possible double rune operator
-		l.accept("&=*")
+		if !l.accept("&=*") && (r == '<' || r == '>') {
+			l.accept(string(r)) // composition operators << and >>
+		}
 		l.emit(Operator)
 	case r == '.':
 		l.backup()
@@ -66,11 +76,13 @@ func number(l *lexer) stateFn {
 
 func (l *lexer) scanNumber() bool {
 	digits := "0123456789_"
+	exponent := "eE"
 	// Is it hex?
 	if l.accept("0") {
 		// Note: Leading 0 does not mean octal in floats.
 		if l.accept("xX") {
 			digits = "0123456789abcdefABCDEF_"
+			exponent = "pP" // hex floats like 0x1.8p+1
 		} else if l.accept("oO") {
 			digits = "01234567_"
 		} else if l.accept("bB") {
@@ -90,9 +102,9 @@ func (l *lexer) scanNumber() bool {
 		}
 		l.acceptRun(digits)
 	}
-	if l.accept("eE") {
+	if l.accept(exponent) {
 		l.accept("+-")
-		l.acceptRun(digits)
+		l.acceptRun("0123456789_")
 	}
 	// Next thing mustn't be alphanumeric.
 	if utils.IsAlphaNumeric(l.peek()) {
@@ -108,11 +120,62 @@ func dot(l *lexer) stateFn {
 		l.backup()
 		return number
 	}
-	l.accept(".")
+	if l.accept(".") {
+		l.accept(".") // spread operator ...
+	}
 	l.emit(Operator)
 	return root
 }
 
+// customLiteral lexes prefix followed by alphanumeric characters and dots.
+func customLiteral(prefix string) stateFn {
+	return func(l *lexer) stateFn {
+		for range prefix {
+			l.next()
+		}
+		start := l.end
+		if r := l.next(); r == '\'' || r == '"' || r == '`' {
+			l.scanString(r)
+			str, err := unescape(l.input[start:l.end])
+			if err != nil {
+				return l.error("%v", err)
+			}
+			l.emitValue(CustomLiteral, str)
+			return root
+		}
+		for r := l.next(); utils.IsAlphaNumeric(r) || r == '.'; r = l.next() {
+		}
+		l.backup()
+		l.emitValue(CustomLiteral, l.input[start:l.end])
+		return root
+	}
+}
+
+// literalPrefix returns custom literal prefix starting at current position.
+// Prefix must be followed by a quote, or by a digit if prefix does not end
+// with a letter, so identifiers like $env or ipVersion are not literals.
+func (l *lexer) literalPrefix() (string, bool) {
+	rest := l.input[l.end:]
+	for _, prefix := range l.literals {
+		if !strings.HasPrefix(rest, prefix) {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(rest[len(prefix):])
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			return prefix, true
+		case unicode.IsDigit(r) && !endsWithWordChar(prefix):
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+func endsWithWordChar(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
 func identifier(l *lexer) stateFn {
 loop:
 	for {
@@ -124,7 +187,8 @@ loop:
 			switch l.word() {
 			case "not":
 				return not
-			case "in", "or", "and", "matches", "contains", "startsWith", "endsWith":
+			case "in", "or", "and", "matches", "contains", "startsWith", "endsWith", "equalsIgnoreCase",
+				"semverEq", "semverGt", "semverGte", "semverLt", "semverLte":
 				l.emit(Operator)
 			case "let":
 				l.emit(Operator)
@@ -156,7 +220,8 @@ func not(l *lexer) stateFn {
 	}
 
 	switch l.word() {
-	case "in", "matches", "contains", "startsWith", "endsWith":
+	case "in", "matches", "contains", "startsWith", "endsWith", "equalsIgnoreCase",
+		"semverEq", "semverGt", "semverGte", "semverLt", "semverLte":
 		l.emit(Operator)
 	default:
 		l.end, l.loc, l.prev = pos, loc, prev
@@ -214,10 +279,24 @@ func pointer(l *lexer) stateFn {
 		case utils.IsAlphaNumeric(r): // absorb
 		default:
 			l.backup()
-			if l.word() != "" {
-				l.emit(Identifier)
+			if word := l.word(); word != "" {
+				if isDigits(word) {
+					// Tuple element index like #0.
+					l.emit(Number)
+				} else {
+					l.emit(Identifier)
+				}
 			}
 			return root
 		}
 	}
 }
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
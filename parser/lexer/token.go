@@ -2,25 +2,31 @@ package lexer
 
 import (
 	"fmt"
-	
+
 	"github.com/oarkflow/expr/file"
 )
 
 type Kind string
 
 const (
-	Identifier Kind = "Identifier"
-	Number     Kind = "Number"
-	String     Kind = "String"
-	Operator   Kind = "Operator"
-	Bracket    Kind = "Bracket"
-	EOF        Kind = "EOF"
+	Identifier    Kind = "Identifier"
+	Number        Kind = "Number"
+	String        Kind = "String"
+	Operator      Kind = "Operator"
+	Bracket       Kind = "Bracket"
+	CustomLiteral Kind = "CustomLiteral" // Value is literal without prefix, Raw includes it
+	EOF           Kind = "EOF"
 )
 
+func (k Kind) String() string {
+	return string(k)
+}
+
 type Token struct {
 	file.Location
 	Kind  Kind
 	Value string
+	Raw   string // source text of token, including quotes and escapes
 }
 
 func (t Token) String() string {
@@ -34,7 +40,7 @@ func (t Token) Is(kind Kind, values ...string) bool {
 	if len(values) == 0 {
 		return kind == t.Kind
 	}
-	
+
 	for _, v := range values {
 		if v == t.Value {
 			goto found
@@ -45,3 +51,8 @@ func (t Token) Is(kind Kind, values ...string) bool {
 found:
 	return kind == t.Kind
 }
+
+// IsOperator reports whether t is operator op.
+func (t Token) IsOperator(op string) bool {
+	return t.Kind == Operator && t.Value == op
+}
@@ -0,0 +1,50 @@
+package parser
+
+import "github.com/oarkflow/expr/ast"
+
+// TreeMetrics describes size of parsed expression, for example to reject
+// too large expressions.
+type TreeMetrics struct {
+	NodeCount      int
+	MaxDepth       int            // nesting of nodes, 1 for a single node
+	OperatorCounts map[string]int // uses of unary and binary operators
+	BuiltinCounts  map[string]int // calls of builtins by name
+}
+
+// Metrics returns metrics of tree, computed on first call. Maps of
+// returned metrics are shared between calls and should not be modified.
+func (t *Tree) Metrics() TreeMetrics {
+	if m := t.metrics.Load(); m != nil {
+		return *m
+	}
+	m := &TreeMetrics{
+		OperatorCounts: make(map[string]int),
+		BuiltinCounts:  make(map[string]int),
+	}
+	m.MaxDepth = m.add(t.Node)
+	t.metrics.Store(m)
+	return *m
+}
+
+// add counts node and its children, and returns depth of node.
+func (m *TreeMetrics) add(node ast.Node) int {
+	if node == nil {
+		return 0
+	}
+	m.NodeCount++
+	switch n := node.(type) {
+	case *ast.UnaryNode:
+		m.OperatorCounts[n.Operator]++
+	case *ast.BinaryNode:
+		m.OperatorCounts[n.Operator]++
+	case *ast.BuiltinNode:
+		m.BuiltinCounts[n.Name]++
+	}
+	depth := 0
+	for _, child := range ast.Children(node) {
+		if d := m.add(child); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
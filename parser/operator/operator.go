@@ -1,5 +1,7 @@
 package operator
 
+import "sort"
+
 type Associativity int
 
 const (
@@ -10,43 +12,80 @@ const (
 type Operator struct {
 	Precedence    int
 	Associativity Associativity
+	Description   string
+}
+
+// BinaryOperator is an operator of Binary with its name.
+type BinaryOperator struct {
+	Name string
+	Operator
 }
 
 func Less(a, b string) bool {
 	return Binary[a].Precedence < Binary[b].Precedence
 }
 
+// AllBinaryOps returns binary operators sorted by precedence and name.
+func AllBinaryOps() []BinaryOperator {
+	ops := make([]BinaryOperator, 0, len(Binary))
+	for name, op := range Binary {
+		ops = append(ops, BinaryOperator{Name: name, Operator: op})
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Precedence != ops[j].Precedence {
+			return ops[i].Precedence < ops[j].Precedence
+		}
+		return ops[i].Name < ops[j].Name
+	})
+	return ops
+}
+
 var Unary = map[string]Operator{
-	"not": {50, Left},
-	"!":   {50, Left},
-	"-":   {90, Left},
-	"+":   {90, Left},
+	"not": {50, Left, "logical not"},
+	"!":   {50, Left, "logical not"},
+	"-":   {90, Left, "negation"},
+	"+":   {90, Left, "unary plus"},
 }
 
 var Binary = map[string]Operator{
-	"|":          {0, Left},
-	"or":         {10, Left},
-	"||":         {10, Left},
-	"and":        {15, Left},
-	"&&":         {15, Left},
-	"==":         {20, Left},
-	"!=":         {20, Left},
-	"<":          {20, Left},
-	">":          {20, Left},
-	">=":         {20, Left},
-	"<=":         {20, Left},
-	"in":         {20, Left},
-	"matches":    {20, Left},
-	"contains":   {20, Left},
-	"startsWith": {20, Left},
-	"endsWith":   {20, Left},
-	"..":         {25, Left},
-	"+":          {30, Left},
-	"-":          {30, Left},
-	"*":          {60, Left},
-	"/":          {60, Left},
-	"%":          {60, Left},
-	"**":         {100, Right},
-	"^":          {100, Right},
-	"??":         {500, Left},
+	// Composition binds looser than pipe, so `x | f >> g` composes
+	// result of x | f with g, and `f >> g | h` is f >> (g | h).
+	">>":           {0, Left, "compose functions left to right"},
+	"<<":           {0, Left, "compose functions right to left"},
+	"|":            {1, Left, "pipe value into function"},
+	"|>":           {1, Left, "pipe value into function, same as |"},
+	"or":           {10, Left, "logical or"},
+	"||":           {10, Left, "logical or"},
+	"and":          {15, Left, "logical and"},
+	"&&":           {15, Left, "logical and"},
+	"==":           {20, Left, "equal"},
+	"!=":           {20, Left, "not equal"},
+	"<":            {20, Left, "less than"},
+	">":            {20, Left, "greater than"},
+	">=":           {20, Left, "greater than or equal"},
+	"<=":           {20, Left, "less than or equal"},
+	"in":           {20, Left, "element of array, key of map or field of struct"},
+	"not in":       {20, Left, "negation of in"},
+	"matches":      {20, Left, "matches regular expression"},
+	"contains":     {20, Left, "string contains substring"},
+	"not contains": {20, Left, "negation of contains"},
+	"startsWith":   {20, Left, "string has prefix"},
+	"endsWith":     {20, Left, "string has suffix"},
+	// equalsIgnoreCase compares strings with Unicode case folding
+	// (strings.EqualFold), not with locale-specific collation.
+	"equalsIgnoreCase": {20, Left, "strings are equal under Unicode case folding"},
+	"semverEq":         {20, Left, "semantic versions are equal"},
+	"semverGt":         {20, Left, "semantic version is greater"},
+	"semverGte":        {20, Left, "semantic version is greater or equal"},
+	"semverLt":         {20, Left, "semantic version is less"},
+	"semverLte":        {20, Left, "semantic version is less or equal"},
+	"..":               {25, Left, "range of integers"},
+	"+":                {30, Left, "addition or concatenation"},
+	"-":                {30, Left, "subtraction"},
+	"*":                {60, Left, "multiplication"},
+	"/":                {60, Left, "division"},
+	"%":                {60, Left, "modulo"},
+	"**":               {100, Right, "exponent"},
+	"^":                {100, Right, "exponent"},
+	"??":               {500, Left, "nil coalescing"},
 }
@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/builtin"
@@ -16,21 +18,28 @@ import (
 )
 
 var predicates = map[string]struct {
-	arity int
+	arity    int
+	optional bool // accepts one more argument after the predicate
 }{
-	"all":           {2},
-	"none":          {2},
-	"any":           {2},
-	"one":           {2},
-	"filter":        {2},
-	"map":           {2},
-	"count":         {2},
-	"find":          {2},
-	"findIndex":     {2},
-	"findLast":      {2},
-	"findLastIndex": {2},
-	"groupBy":       {2},
-	"reduce":        {3},
+	"all":           {2, false},
+	"none":          {2, false},
+	"any":           {2, false},
+	"one":           {2, false},
+	"filter":        {2, false},
+	"map":           {2, false},
+	"count":         {2, false},
+	"find":          {2, true}, // default value
+	"findIndex":     {2, false},
+	"findLast":      {2, true}, // default value
+	"findLastIndex": {2, false},
+	"groupBy":       {2, false},
+	"reduce":        {2, true}, // initial value
+}
+
+// pointers are names of variables available inside predicates, like #index.
+var pointers = map[string]bool{
+	"index": true,
+	"acc":   true,
 }
 
 type parser struct {
@@ -40,11 +49,20 @@ type parser struct {
 	err     *file.Error
 	depth   int // closure call depth
 	config  *conf.Config
+	module  bool            // parsing imported module, last let may omit expression
+	imports map[string]bool // modules being imported, to detect cycles
+	hidden  int             // number of hidden variables, used to name them
+	ctx     context.Context
+	steps   int // parse loop iterations, ctx is checked every ctxCheckInterval steps
+	nesting int // depth of parseExpression recursion
 }
 
+const ctxCheckInterval = 64
+
 type Tree struct {
-	Node   ast.Node
-	Source *file.Source
+	Node    ast.Node
+	Source  *file.Source
+	metrics atomic.Pointer[TreeMetrics]
 }
 
 func Parse(input string) (*Tree, error) {
@@ -54,9 +72,15 @@ func Parse(input string) (*Tree, error) {
 }
 
 func ParseWithConfig(input string, config *conf.Config) (*Tree, error) {
+	return ParseWithContext(context.Background(), input, config)
+}
+
+// ParseWithContext is ParseWithConfig which stops with error when ctx
+// is done.
+func ParseWithContext(ctx context.Context, input string, config *conf.Config) (*Tree, error) {
 	source := file.NewSource(input)
 
-	tokens, err := lexer2.Lex(source)
+	tokens, err := lexer2.LexWithLiterals(source, literalPrefixes(config))
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +89,7 @@ func ParseWithConfig(input string, config *conf.Config) (*Tree, error) {
 		tokens:  tokens,
 		current: tokens[0],
 		config:  config,
+		ctx:     ctx,
 	}
 
 	node := p.parseExpression(0)
@@ -73,6 +98,10 @@ func ParseWithConfig(input string, config *conf.Config) (*Tree, error) {
 		p.error("unexpected token %v", p.current)
 	}
 
+	if p.err == nil && config != nil && config.PreludeExpr != "" {
+		node = p.withPrelude(node)
+	}
+
 	if p.err != nil {
 		return nil, p.err.Bind(source)
 	}
@@ -83,6 +112,44 @@ func ParseWithConfig(input string, config *conf.Config) (*Tree, error) {
 	}, nil
 }
 
+// ParsePrelude parses input consisting of let declarations, like
+// "let a = 1; let b = a + 1", where ";" after the last one is optional.
+// Expr of the last declaration of returned tree is nil; Node of the tree
+// is nil if input is empty.
+func ParsePrelude(input string, config *conf.Config) (*Tree, error) {
+	source := file.NewSource(input)
+	tokens, err := lexer2.LexWithLiterals(source, literalPrefixes(config))
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		tokens:  tokens,
+		current: tokens[0],
+		config:  config,
+		module:  true,
+		ctx:     context.Background(),
+	}
+	if p.current.Is(lexer2.EOF) {
+		return &Tree{Source: source}, nil
+	}
+	node := p.parseExpression(0)
+	if p.err == nil && !p.current.Is(lexer2.EOF) {
+		p.error("unexpected token %v", p.current)
+	}
+	for last := node; p.err == nil && last != nil; {
+		let, ok := last.(*ast.VariableDeclaratorNode)
+		if !ok {
+			p.errorAt(p.tokens[0], "prelude should consist of let declarations")
+			break
+		}
+		last = let.Expr
+	}
+	if p.err != nil {
+		return nil, p.err.Bind(source)
+	}
+	return &Tree{Node: node, Source: source}, nil
+}
+
 func (p *parser) error(format string, args ...any) {
 	p.errorAt(p.current, format, args...)
 }
@@ -105,6 +172,33 @@ func (p *parser) next() {
 	p.current = p.tokens[p.pos]
 }
 
+// isPure reports whether builtin may be evaluated at compile time.
+func isPure(name string) bool {
+	def, ok := builtin.Describe(name)
+	return ok && def.Pure
+}
+
+func (p *parser) maxDepth() int {
+	if p.config != nil && p.config.MaxParseDepth > 0 {
+		return p.config.MaxParseDepth
+	}
+	return conf.DefaultMaxParseDepth
+}
+
+// checkContext reports error if parsing context is done.
+func (p *parser) checkContext() {
+	p.steps++
+	if p.steps%ctxCheckInterval != 0 || p.err != nil {
+		return
+	}
+	select {
+	case <-p.ctx.Done():
+		p.error("parsing stopped: %v", p.ctx.Err())
+		p.err.Wrap(p.ctx.Err())
+	default:
+	}
+}
+
 func (p *parser) expect(kind lexer2.Kind, values ...string) {
 	if p.current.Is(kind, values...) {
 		p.next()
@@ -116,17 +210,29 @@ func (p *parser) expect(kind lexer2.Kind, values ...string) {
 // parse functions
 
 func (p *parser) parseExpression(precedence int) ast.Node {
+	p.nesting++
+	defer func() { p.nesting-- }()
+	if p.nesting > p.maxDepth() {
+		p.error("expression is too deeply nested (max depth %d)", p.maxDepth())
+		return &ast.NilNode{}
+	}
+
 	if precedence == 0 {
 		if p.current.Is(lexer2.Operator, "let") {
 			return p.parseVariableDeclaration()
 		}
+		if p.isImport() {
+			return p.parseImport()
+		}
 	}
 
+	p.checkContext()
 	nodeLeft := p.parsePrimary()
 
 	prevOperator := ""
 	opToken := p.current
 	for opToken.Is(lexer2.Operator) && p.err == nil {
+		p.checkContext()
 		negate := false
 		var notToken lexer2.Token
 
@@ -142,7 +248,14 @@ func (p *parser) parseExpression(precedence int) ast.Node {
 			if op.Precedence >= precedence {
 				p.next()
 
-				if opToken.Value == "|" {
+				operatorName := opToken.Value
+				if _, ok := operator.Binary["not "+opToken.Value]; ok && negate {
+					// Negated operators like "not in" have their own node.
+					operatorName = "not " + opToken.Value
+					negate = false
+				}
+
+				if opToken.Value == "|" || opToken.Value == "|>" {
 					nodeLeft = p.parsePipe(nodeLeft)
 					goto next
 				}
@@ -160,7 +273,7 @@ func (p *parser) parseExpression(precedence int) ast.Node {
 				}
 
 				nodeLeft = &ast.BinaryNode{
-					Operator: opToken.Value,
+					Operator: operatorName,
 					Left:     nodeLeft,
 					Right:    nodeRight,
 				}
@@ -193,12 +306,21 @@ func (p *parser) parseExpression(precedence int) ast.Node {
 
 func (p *parser) parseVariableDeclaration() ast.Node {
 	p.expect(lexer2.Operator, "let")
+	if p.current.Is(lexer2.Bracket, "{", "[") {
+		return p.parseDestructuring()
+	}
 	variableName := p.current
 	p.expect(lexer2.Identifier)
 	p.expect(lexer2.Operator, "=")
 	value := p.parseExpression(0)
-	p.expect(lexer2.Operator, ";")
-	node := p.parseExpression(0)
+	if fn, ok := value.(*ast.FunctionNode); ok && fn.Name == "" {
+		fn.Name = variableName.Value
+	}
+	p.expectSemicolon()
+	var node ast.Node
+	if !p.module || !p.current.Is(lexer2.EOF) {
+		node = p.parseExpression(0)
+	}
 	let := &ast.VariableDeclaratorNode{
 		Name:  variableName.Value,
 		Value: value,
@@ -208,6 +330,272 @@ func (p *parser) parseVariableDeclaration() ast.Node {
 	return let
 }
 
+// expectSemicolon expects ";" after let declaration, which may be omitted
+// after the last declaration of a module.
+func (p *parser) expectSemicolon() {
+	if p.module && p.current.Is(lexer2.EOF) {
+		return
+	}
+	p.expect(lexer2.Operator, ";")
+}
+
+// parseDestructuring parses `let {name, age} = user; expr` and
+// `let [first, second] = pair; expr` into a chain of let declarations
+// of each name.
+func (p *parser) parseDestructuring() ast.Node {
+	token := p.current
+	array := token.Value == "["
+	names := p.parseNames()
+	p.expect(lexer2.Operator, "=")
+	value := p.parseExpression(0)
+	p.expectSemicolon()
+	var node ast.Node
+	if !p.module || !p.current.Is(lexer2.EOF) {
+		node = p.parseExpression(0)
+	}
+	return p.bindNames(token, names, array, value, node)
+}
+
+// parseWith parses `with user { name, age } in expr`, which is the same
+// as `let {name, age} = user; expr`.
+func (p *parser) parseWith(token lexer2.Token) ast.Node {
+	value := p.parsePrimary()
+	if !p.current.Is(lexer2.Bracket, "{") {
+		p.error("unexpected token %v", p.current)
+	}
+	names := p.parseNames()
+	p.expect(lexer2.Operator, "in")
+	node := p.parseExpression(0)
+	return p.bindNames(token, names, false, value, node)
+}
+
+// parseNames parses `{a, b}` or `[a, b]` of destructuring.
+func (p *parser) parseNames() []lexer2.Token {
+	token := p.current
+	closing := "}"
+	if token.Value == "[" {
+		closing = "]"
+	}
+	p.next()
+
+	var names []lexer2.Token
+	for !p.current.Is(lexer2.Bracket, closing) && p.err == nil {
+		if len(names) > 0 {
+			p.expect(lexer2.Operator, ",")
+			if p.current.Is(lexer2.Bracket, closing) {
+				break // trailing comma
+			}
+		}
+		names = append(names, p.current)
+		p.expect(lexer2.Identifier)
+	}
+	p.expect(lexer2.Bracket, closing)
+	if len(names) == 0 {
+		p.errorAt(token, "destructuring requires at least one name")
+	}
+	return names
+}
+
+// bindNames wraps node into let declarations of names, reading fields
+// (or elements, if array) of value. Values other than identifiers are
+// evaluated once into a hidden variable.
+func (p *parser) bindNames(token lexer2.Token, names []lexer2.Token, array bool, value, node ast.Node) ast.Node {
+	source := ""
+	hidden := false
+	if id, ok := value.(*ast.IdentifierNode); ok {
+		source = id.Value
+	} else {
+		source = fmt.Sprintf("$let%d", p.hidden)
+		p.hidden++
+		hidden = true
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		from := &ast.IdentifierNode{Value: source}
+		from.SetLocation(names[i].Location)
+		var property ast.Node
+		if array {
+			property = &ast.IntegerNode{Value: i}
+		} else {
+			property = &ast.StringNode{Value: names[i].Value}
+		}
+		property.SetLocation(names[i].Location)
+		member := &ast.MemberNode{Node: from, Property: property}
+		member.SetLocation(names[i].Location)
+		let := &ast.VariableDeclaratorNode{
+			Name:  names[i].Value,
+			Value: member,
+			Expr:  node,
+		}
+		let.SetLocation(names[i].Location)
+		node = let
+	}
+
+	if !hidden {
+		return node
+	}
+	let := &ast.VariableDeclaratorNode{
+		Name:  source,
+		Value: value,
+		Expr:  node,
+	}
+	let.SetLocation(token.Location)
+	return let
+}
+
+// isImport reports whether current token starts `import name from "module"`.
+func (p *parser) isImport() bool {
+	return p.current.Is(lexer2.Identifier, "import") &&
+		p.pos+2 < len(p.tokens) &&
+		p.tokens[p.pos+1].Is(lexer2.Identifier) &&
+		p.tokens[p.pos+2].Is(lexer2.Identifier, "from")
+}
+
+// parseImport parses `import name from "module"; expr`. Source of the module
+// is fetched with conf.Config.Loader and should consist of let declarations.
+// All declared names are available as fields of name, like name.clamp.
+func (p *parser) parseImport() ast.Node {
+	token := p.current
+	p.next()
+	name := p.current
+	p.next()
+	p.next() // from
+	path := p.current
+	p.expect(lexer2.String)
+
+	module := p.parseModule(path)
+	p.expect(lexer2.Operator, ";")
+	node := p.parseExpression(0)
+
+	let := &ast.VariableDeclaratorNode{
+		Name:  name.Value,
+		Value: module,
+		Expr:  node,
+	}
+	let.SetLocation(token.Location)
+	return let
+}
+
+// parseModule loads and parses module, and replaces the end of its let
+// declarations with a map of all declared names.
+func (p *parser) parseModule(path lexer2.Token) ast.Node {
+	if p.err != nil {
+		return &ast.NilNode{}
+	}
+	if p.config == nil || p.config.Loader == nil {
+		p.errorAt(path, "cannot import %q: no loader configured", path.Value)
+		return &ast.NilNode{}
+	}
+	if p.imports[path.Value] {
+		p.errorAt(path, "import cycle with %q", path.Value)
+		return &ast.NilNode{}
+	}
+	input, err := p.config.Loader(path.Value)
+	if err != nil {
+		p.errorAt(path, "cannot import %q: %v", path.Value, err)
+		return &ast.NilNode{}
+	}
+	tokens, err := lexer2.LexWithLiterals(file.NewSource(input), literalPrefixes(p.config))
+	if err != nil {
+		p.errorAt(path, "cannot import %q: %v", path.Value, err)
+		return &ast.NilNode{}
+	}
+
+	imports := map[string]bool{path.Value: true}
+	for name := range p.imports {
+		imports[name] = true
+	}
+	mp := &parser{
+		tokens:  tokens,
+		current: tokens[0],
+		config:  p.config,
+		module:  true,
+		imports: imports,
+		ctx:     p.ctx,
+	}
+	node := mp.parseExpression(0)
+	if mp.err == nil && !mp.current.Is(lexer2.EOF) {
+		mp.error("unexpected token %v", mp.current)
+	}
+	if mp.err != nil {
+		p.errorAt(path, "cannot import %q: %v", path.Value, mp.err.Message)
+		return &ast.NilNode{}
+	}
+
+	exports := &ast.MapNode{}
+	exports.SetLocation(path.Location)
+	for last := node; ; {
+		let, ok := last.(*ast.VariableDeclaratorNode)
+		if !ok {
+			p.errorAt(path, "module %q should consist of let declarations", path.Value)
+			return &ast.NilNode{}
+		}
+		if !strings.HasPrefix(let.Name, "$") { // hidden variables are not exported
+			key := &ast.StringNode{Value: let.Name}
+			key.SetLocation(path.Location)
+			value := &ast.IdentifierNode{Value: let.Name}
+			value.SetLocation(path.Location)
+			pair := &ast.PairNode{Key: key, Value: value}
+			pair.SetLocation(path.Location)
+			exports.Pairs = append(exports.Pairs, pair)
+		}
+		if let.Expr == nil {
+			let.Expr = exports
+			return node
+		}
+		last = let.Expr
+	}
+}
+
+// literalPrefixes returns prefixes of custom literals of config.
+func literalPrefixes(config *conf.Config) []string {
+	if config == nil {
+		return nil
+	}
+	prefixes := make([]string, 0, len(config.CustomLiteralParser))
+	for prefix := range config.CustomLiteralParser {
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// withPrelude parses conf.Config.PreludeExpr, which should consist of let
+// declarations, and makes node the expression of its last declaration.
+func (p *parser) withPrelude(node ast.Node) ast.Node {
+	tokens, err := lexer2.LexWithLiterals(file.NewSource(p.config.PreludeExpr), literalPrefixes(p.config))
+	if err != nil {
+		p.err = &file.Error{Message: fmt.Sprintf("prelude: %v", err)}
+		return node
+	}
+	pp := &parser{
+		tokens:  tokens,
+		current: tokens[0],
+		config:  p.config,
+		module:  true,
+		ctx:     p.ctx,
+	}
+	prelude := pp.parseExpression(0)
+	if pp.err == nil && !pp.current.Is(lexer2.EOF) {
+		pp.error("unexpected token %v", pp.current)
+	}
+	if pp.err != nil {
+		p.err = &file.Error{Message: fmt.Sprintf("prelude: %v", pp.err.Message)}
+		return node
+	}
+	for last := prelude; ; {
+		let, ok := last.(*ast.VariableDeclaratorNode)
+		if !ok {
+			p.err = &file.Error{Message: "prelude should consist of let declarations"}
+			return node
+		}
+		if let.Expr == nil {
+			let.Expr = node
+			return prelude
+		}
+		last = let.Expr
+	}
+}
+
 func (p *parser) parseConditional(node ast.Node) ast.Node {
 	var expr1, expr2 ast.Node
 	for p.current.Is(lexer2.Operator, "?") && p.err == nil {
@@ -263,8 +651,33 @@ func (p *parser) parsePrimary() ast.Node {
 				if p.current.Is(lexer2.Identifier) {
 					name = p.current.Value
 					p.next()
+				} else if p.current.Is(lexer2.Number) {
+					// Shorthand #0 for #[0], element of a tuple like pairs of zip.
+					number := p.current
+					p.next()
+					value, err := strconv.Atoi(number.Value)
+					if err != nil {
+						p.errorAt(number, "invalid pointer index %v", number.Value)
+					}
+					pointer := &ast.PointerNode{}
+					pointer.SetLocation(token.Location)
+					index := &ast.IntegerNode{Value: value}
+					index.SetLocation(number.Location)
+					node := &ast.MemberNode{Node: pointer, Property: index}
+					node.SetLocation(token.Location)
+					return p.parsePostfixExpression(node)
 				}
 			}
+			if name != "" && !pointers[name] {
+				// Shorthand #field for #.field.
+				pointer := &ast.PointerNode{}
+				pointer.SetLocation(token.Location)
+				property := &ast.StringNode{Value: name}
+				property.SetLocation(token.Location)
+				node := &ast.MemberNode{Node: pointer, Property: property}
+				node.SetLocation(token.Location)
+				return p.parsePostfixExpression(node)
+			}
 			node := &ast.PointerNode{Name: name}
 			node.SetLocation(token.Location)
 			return p.parsePostfixExpression(node)
@@ -299,14 +712,47 @@ func (p *parser) parseSecondary() ast.Node {
 			node := &ast.NilNode{}
 			node.SetLocation(token.Location)
 			return node
+		case "func":
+			if p.current.Is(lexer2.Bracket, "(") {
+				return p.parseFunction(token)
+			}
+			node = p.parseCall(token)
+		case "switch":
+			if p.isSwitch() {
+				return p.parseSwitch(token)
+			}
+			node = p.parseCall(token)
+		case "do":
+			if p.current.Is(lexer2.Bracket, "{") {
+				return p.parseBlock(token)
+			}
+			node = p.parseCall(token)
+		case "with":
+			if p.current.Is(lexer2.Identifier) {
+				return p.parseWith(token)
+			}
+			fallthrough
 		default:
+			if name, ok := p.groupFunction(token); ok {
+				p.next()
+				p.next()
+				token.Value = name
+			}
 			node = p.parseCall(token)
 		}
 
 	case lexer2.Number:
 		p.next()
 		value := strings.Replace(token.Value, "_", "", -1)
-		if strings.Contains(value, "x") {
+		if strings.ContainsAny(value, "xX") && strings.ContainsAny(value, ".pP") {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				p.error("invalid hex float literal: %v", err)
+			}
+			node := &ast.FloatNode{Value: number}
+			node.SetLocation(token.Location)
+			return node
+		} else if strings.ContainsAny(value, "xXoObB") {
 			number, err := strconv.ParseInt(value, 0, 64)
 			if err != nil {
 				p.error("invalid hex literal: %v", err)
@@ -346,6 +792,22 @@ func (p *parser) parseSecondary() ast.Node {
 		node.SetLocation(token.Location)
 		return node
 
+	case lexer2.CustomLiteral:
+		p.next()
+		prefix := ""
+		for k := range p.config.CustomLiteralParser {
+			if strings.HasPrefix(token.Raw, k) && len(k) > len(prefix) {
+				prefix = k
+			}
+		}
+		value, err := p.config.CustomLiteralParser[prefix](token.Value)
+		if err != nil {
+			p.errorAt(token, "invalid %s literal: %v", prefix, err)
+		}
+		node := &ast.ConstantNode{Value: value, OriginalExpr: token.Raw}
+		node.SetLocation(token.Location)
+		return node
+
 	default:
 		if token.Is(lexer2.Bracket, "[") {
 			node = p.parseArrayExpression(token)
@@ -379,15 +841,9 @@ func (p *parser) parseCall(token lexer2.Token) ast.Node {
 				arguments[1] = p.parseClosure()
 			}
 
-			if token.Value == "reduce" {
-				arguments = make([]ast.Node, 2)
-				arguments[0] = p.parseExpression(0)
-				p.expect(lexer2.Operator, ",")
-				arguments[1] = p.parseClosure()
-				if p.current.Is(lexer2.Operator, ",") {
-					p.next()
-					arguments = append(arguments, p.parseExpression(0))
-				}
+			if b.optional && p.current.Is(lexer2.Operator, ",") {
+				p.next()
+				arguments = append(arguments, p.parseExpression(0))
 			}
 
 			p.expect(lexer2.Bracket, ")")
@@ -401,6 +857,7 @@ func (p *parser) parseCall(token lexer2.Token) ast.Node {
 			node = &ast.BuiltinNode{
 				Name:      token.Value,
 				Arguments: p.parseArguments(),
+				Pure:      isPure(token.Value),
 			}
 			node.SetLocation(token.Location)
 		} else {
@@ -419,6 +876,9 @@ func (p *parser) parseCall(token lexer2.Token) ast.Node {
 	return node
 }
 
+// parseClosure parses predicate argument. Braces are optional: bare
+// expressions like filter(users, #.role == "admin") also refer to the
+// current element with #.
 func (p *parser) parseClosure() ast.Node {
 	startToken := p.current
 	expectClosingBracket := false
@@ -433,6 +893,16 @@ func (p *parser) parseClosure() ast.Node {
 
 	if expectClosingBracket {
 		p.expect(lexer2.Bracket, "}")
+	} else if bin, ok := node.(*ast.BinaryNode); ok && (bin.Operator == ">>" || bin.Operator == "<<") {
+		// Composed function used as predicate, like map(trim >> upper),
+		// is applied to the current element.
+		pointer := &ast.PointerNode{}
+		pointer.SetLocation(bin.Location())
+		node = &ast.CallNode{
+			Callee:    node,
+			Arguments: []ast.Node{pointer},
+		}
+		node.SetLocation(bin.Location())
 	}
 	closure := &ast.ClosureNode{
 		Node: node,
@@ -441,6 +911,32 @@ func (p *parser) parseClosure() ast.Node {
 	return closure
 }
 
+func (p *parser) parseFunction(token lexer2.Token) ast.Node {
+	var params []string
+
+	p.expect(lexer2.Bracket, "(")
+	for !p.current.Is(lexer2.Bracket, ")") && p.err == nil {
+		if len(params) > 0 {
+			p.expect(lexer2.Operator, ",")
+		}
+		param := p.current
+		p.expect(lexer2.Identifier)
+		params = append(params, param.Value)
+	}
+	p.expect(lexer2.Bracket, ")")
+
+	p.expect(lexer2.Bracket, "{")
+	body := p.parseExpression(0)
+	p.expect(lexer2.Bracket, "}")
+
+	node := &ast.FunctionNode{
+		Params: params,
+		Body:   body,
+	}
+	node.SetLocation(token.Location)
+	return node
+}
+
 func (p *parser) parseArrayExpression(token lexer2.Token) ast.Node {
 	nodes := make([]ast.Node, 0)
 
@@ -497,6 +993,13 @@ func (p *parser) parseMapExpression(token lexer2.Token) ast.Node {
 		p.expect(lexer2.Operator, ":")
 
 		node := p.parseExpression(0)
+		if fn, ok := node.(*ast.FunctionNode); ok && fn.Name == "" {
+			// Functions of namespaces like {fact: func(n) { ... fact(n - 1) }}
+			// can call themselves by key.
+			if name, ok := key.(*ast.StringNode); ok && utils.IsValidIdentifier(name.Value) {
+				fn.Name = name.Value
+			}
+		}
 		pair := &ast.PairNode{Key: key, Value: node}
 		pair.SetLocation(token.Location)
 		nodes = append(nodes, pair)
@@ -513,7 +1016,28 @@ end:
 func (p *parser) parsePostfixExpression(node ast.Node) ast.Node {
 	postfixToken := p.current
 	for (postfixToken.Is(lexer2.Operator) || postfixToken.Is(lexer2.Bracket)) && p.err == nil {
-		if postfixToken.Value == "." || postfixToken.Value == "?." {
+		p.checkContext()
+		if postfixToken.Value == "?." && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Is(lexer2.Bracket, "[") {
+			// Optional index access, like arr?.[0].
+			p.next()
+			p.next()
+
+			chainNode, isChain := node.(*ast.ChainNode)
+			if isChain {
+				node = chainNode.Node
+			}
+
+			memberNode := &ast.MemberNode{
+				Node:     node,
+				Property: p.parseExpression(0),
+				Optional: true,
+			}
+			memberNode.SetLocation(postfixToken.Location)
+			p.expect(lexer2.Bracket, "]")
+
+			node = &ast.ChainNode{Node: memberNode}
+
+		} else if postfixToken.Value == "." || postfixToken.Value == "?." {
 			p.next()
 
 			propertyToken := p.current
@@ -560,6 +1084,13 @@ func (p *parser) parsePostfixExpression(node ast.Node) ast.Node {
 			p.next()
 			var from, to ast.Node
 
+			// Index of optional chain, like a?.b[0], belongs to the chain,
+			// so the whole chain is short-circuited to nil at once.
+			chainNode, isChain := node.(*ast.ChainNode)
+			if isChain {
+				node = chainNode.Node
+			}
+
 			if p.current.Is(lexer2.Operator, ":") { // slice without from [:1]
 				p.next()
 
@@ -604,6 +1135,17 @@ func (p *parser) parsePostfixExpression(node ast.Node) ast.Node {
 					p.expect(lexer2.Bracket, "]")
 				}
 			}
+
+			if isChain {
+				node = &ast.ChainNode{Node: node}
+			}
+		} else if postfixToken.Value == "(" {
+			// Call of a function returned by an expression, like add(1)(2).
+			node = &ast.CallNode{
+				Callee:    node,
+				Arguments: p.parseArguments(),
+			}
+			node.SetLocation(postfixToken.Location)
 		} else {
 			break
 		}
@@ -627,12 +1169,9 @@ func (p *parser) parsePipe(node ast.Node) ast.Node {
 			arguments = append(arguments, p.parseClosure())
 		}
 
-		if identifier.Value == "reduce" {
-			arguments = append(arguments, p.parseClosure())
-			if p.current.Is(lexer2.Operator, ",") {
-				p.next()
-				arguments = append(arguments, p.parseExpression(0))
-			}
+		if b.optional && p.current.Is(lexer2.Operator, ",") {
+			p.next()
+			arguments = append(arguments, p.parseExpression(0))
 		}
 
 		p.expect(lexer2.Bracket, ")")
@@ -643,18 +1182,19 @@ func (p *parser) parsePipe(node ast.Node) ast.Node {
 		}
 		node.SetLocation(identifier.Location)
 	} else if _, ok := builtin.Index[identifier.Value]; ok {
-		arguments = append(arguments, p.parseArguments()...)
+		arguments = append(arguments, p.parsePipeArguments()...)
 
 		node = &ast.BuiltinNode{
 			Name:      identifier.Value,
 			Arguments: arguments,
+			Pure:      isPure(identifier.Value),
 		}
 		node.SetLocation(identifier.Location)
 	} else {
 		callee := &ast.IdentifierNode{Value: identifier.Value}
 		callee.SetLocation(identifier.Location)
 
-		arguments = append(arguments, p.parseArguments()...)
+		arguments = append(arguments, p.parsePipeArguments()...)
 
 		node = &ast.CallNode{
 			Callee:    callee,
@@ -666,6 +1206,15 @@ func (p *parser) parsePipe(node ast.Node) ast.Node {
 	return node
 }
 
+// parsePipeArguments parses arguments after piped value. Parentheses may be
+// omitted if there are none, like "hello" | upper.
+func (p *parser) parsePipeArguments() []ast.Node {
+	if !p.current.Is(lexer2.Bracket, "(") {
+		return nil
+	}
+	return p.parseArguments()
+}
+
 func (p *parser) parseArguments() []ast.Node {
 	p.expect(lexer2.Bracket, "(")
 	nodes := make([]ast.Node, 0)
@@ -673,10 +1222,251 @@ func (p *parser) parseArguments() []ast.Node {
 		if len(nodes) > 0 {
 			p.expect(lexer2.Operator, ",")
 		}
-		node := p.parseExpression(0)
+		var node ast.Node
+		if p.current.Is(lexer2.Operator, "...") {
+			token := p.current
+			p.next()
+			node = &ast.SpreadNode{Node: p.parseExpression(0)}
+			node.SetLocation(token.Location)
+		} else if p.isClosure() {
+			node = p.parseClosure()
+		} else {
+			node = p.parseExpression(0)
+		}
 		nodes = append(nodes, node)
 	}
 	p.expect(lexer2.Bracket, ")")
 
 	return nodes
 }
+
+// parseBlock parses `do { let x = f(); g(x); x + 1 }`.
+func (p *parser) parseBlock(token lexer2.Token) ast.Node {
+	p.expect(lexer2.Bracket, "{")
+	block := &ast.BlockNode{}
+	block.SetLocation(token.Location)
+	for p.err == nil {
+		if p.current.Is(lexer2.Operator, "let") {
+			p.next()
+			name := p.current
+			p.expect(lexer2.Identifier)
+			p.expect(lexer2.Operator, "=")
+			value := p.parseExpression(0)
+			if fn, ok := value.(*ast.FunctionNode); ok && fn.Name == "" {
+				fn.Name = name.Value
+			}
+			let := &ast.VariableDeclaratorNode{Name: name.Value, Value: value}
+			let.SetLocation(name.Location)
+			block.Statements = append(block.Statements, let)
+			p.expect(lexer2.Operator, ";")
+			continue
+		}
+		node := p.parseExpression(0)
+		if p.current.Is(lexer2.Operator, ";") {
+			p.next()
+			if !p.current.Is(lexer2.Bracket, "}") {
+				block.Statements = append(block.Statements, node)
+				continue
+			}
+		}
+		block.Result = node
+		break
+	}
+	p.expect(lexer2.Bracket, "}")
+	return block
+}
+
+// groupFunction reports whether token starts a call of function registered
+// in a group, like math.abs(x), and returns full name of the function.
+// Identifiers from environment take precedence over groups.
+func (p *parser) groupFunction(token lexer2.Token) (string, bool) {
+	if !p.current.Is(lexer2.Operator, ".") || p.pos+2 >= len(p.tokens) {
+		return "", false
+	}
+	if !p.tokens[p.pos+1].Is(lexer2.Identifier) || !p.tokens[p.pos+2].Is(lexer2.Bracket, "(") {
+		return "", false
+	}
+	if p.config == nil {
+		return "", false
+	}
+	if _, ok := p.config.Types[token.Value]; ok {
+		return "", false
+	}
+	name := token.Value + "." + p.tokens[p.pos+1].Value
+	if _, ok := p.config.Functions[name]; !ok {
+		return "", false
+	}
+	return name, true
+}
+
+// isSwitch reports whether tokens after switch keyword are
+// `(subject) {` or `{`, to distinguish from a call of switch function.
+func (p *parser) isSwitch() bool {
+	if p.current.Is(lexer2.Bracket, "{") {
+		return true
+	}
+	if !p.current.Is(lexer2.Bracket, "(") {
+		return false
+	}
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		token := p.tokens[i]
+		switch {
+		case token.Is(lexer2.EOF):
+			return false
+		case token.Is(lexer2.Bracket, "(", "[", "{"):
+			depth++
+		case token.Is(lexer2.Bracket, ")", "]", "}"):
+			depth--
+			if depth == 0 {
+				return i+1 < len(p.tokens) && p.tokens[i+1].Is(lexer2.Bracket, "{")
+			}
+		}
+	}
+	return false
+}
+
+// parseSwitch parses `switch(subject) { case a: x, case b: y, default: z }`
+// into a chain of conditional nodes comparing subject with each case.
+// Subject is evaluated once and stored in a variable. Without subject,
+// like `switch { case cond: x, default: y }`, cases are conditions.
+func (p *parser) parseSwitch(token lexer2.Token) ast.Node {
+	var subject ast.Node
+	if p.current.Is(lexer2.Bracket, "(") {
+		p.next()
+		subject = p.parseExpression(0)
+		p.expect(lexer2.Bracket, ")")
+	}
+	p.expect(lexer2.Bracket, "{")
+
+	type switchCase struct {
+		token lexer2.Token
+		cond  ast.Node
+		expr  ast.Node
+	}
+	var cases []switchCase
+	var fallback ast.Node
+	for !p.current.Is(lexer2.Bracket, "}") && p.err == nil {
+		if len(cases) > 0 || fallback != nil {
+			if p.current.Is(lexer2.Operator, ",", ";") {
+				p.next()
+			}
+			if p.current.Is(lexer2.Bracket, "}") {
+				break // trailing comma
+			}
+		}
+		if fallback != nil {
+			p.error("default must be the last case of switch")
+			break
+		}
+		caseToken := p.current
+		if p.current.Is(lexer2.Identifier, "default") {
+			p.next()
+			p.expect(lexer2.Operator, ":")
+			fallback = p.parseExpression(0)
+			continue
+		}
+		p.expect(lexer2.Identifier, "case")
+		cond := p.parseExpression(1) // stop before ternary "?" and ":"
+		p.expect(lexer2.Operator, ":")
+		expr := p.parseExpression(0)
+		cases = append(cases, switchCase{caseToken, cond, expr})
+	}
+	p.expect(lexer2.Bracket, "}")
+
+	if fallback == nil {
+		fallback = &ast.NilNode{}
+		fallback.SetLocation(token.Location)
+	}
+
+	// Literal subjects are compared directly, so optimizer can fold them.
+	literal := false
+	switch subject.(type) {
+	case *ast.NilNode, *ast.BoolNode, *ast.IntegerNode, *ast.FloatNode, *ast.StringNode:
+		literal = true
+	}
+
+	name := fmt.Sprintf("$switch%d", p.hidden)
+	p.hidden++
+
+	node := fallback
+	for i := len(cases) - 1; i >= 0; i-- {
+		cond := cases[i].cond
+		if subject != nil {
+			var value ast.Node
+			if literal && i == 0 {
+				value = subject
+			} else if literal {
+				value = copyLiteral(subject)
+			} else {
+				value = &ast.IdentifierNode{Value: name}
+				value.SetLocation(subject.Location())
+			}
+			cond = &ast.BinaryNode{Operator: "==", Left: value, Right: cond}
+			cond.SetLocation(cases[i].token.Location)
+		}
+		node = &ast.ConditionalNode{Cond: cond, Exp1: cases[i].expr, Exp2: node}
+		node.SetLocation(cases[i].token.Location)
+	}
+
+	if subject != nil && !literal {
+		node = &ast.VariableDeclaratorNode{Name: name, Value: subject, Expr: node}
+		node.SetLocation(token.Location)
+	}
+	return node
+}
+
+func copyLiteral(node ast.Node) ast.Node {
+	var c ast.Node
+	switch n := node.(type) {
+	case *ast.NilNode:
+		c = &ast.NilNode{}
+	case *ast.BoolNode:
+		c = &ast.BoolNode{Value: n.Value}
+	case *ast.IntegerNode:
+		c = &ast.IntegerNode{Value: n.Value}
+	case *ast.FloatNode:
+		c = &ast.FloatNode{Value: n.Value}
+	case *ast.StringNode:
+		c = &ast.StringNode{Value: n.Value}
+	default:
+		panic(fmt.Sprintf("unexpected literal %T", node))
+	}
+	c.SetLocation(node.Location())
+	return c
+}
+
+// isClosure reports whether the current "{" starts a closure, like { # * 2 },
+// rather than a map literal. Maps are told apart by a top-level ":" which
+// does not belong to a ternary operator.
+func (p *parser) isClosure() bool {
+	if !p.current.Is(lexer2.Bracket, "{") {
+		return false
+	}
+	depth, ternary := 0, 0
+	for i := p.pos + 1; i < len(p.tokens); i++ {
+		token := p.tokens[i]
+		switch {
+		case token.Is(lexer2.EOF):
+			return false
+		case token.Is(lexer2.Bracket, "(", "[", "{"):
+			depth++
+		case token.Is(lexer2.Bracket, ")", "]", "}"):
+			if depth == 0 {
+				return token.Value == "}" && i > p.pos+1 // {} is an empty map
+			}
+			depth--
+		case depth > 0:
+		case token.Is(lexer2.Operator, "?"):
+			ternary++
+		case token.Is(lexer2.Operator, ":"):
+			if ternary == 0 {
+				return false
+			}
+			ternary--
+		case token.Is(lexer2.Operator, ","):
+			return false
+		}
+	}
+	return false
+}
@@ -25,8 +25,10 @@ func IsSpace(r rune) bool {
 	return unicode.IsSpace(r)
 }
 
+// IsAlphaNumeric reports whether r may continue an identifier. Combining
+// marks are allowed, as many scripts (Devanagari, Thai, etc.) need them.
 func IsAlphaNumeric(r rune) bool {
-	return IsAlphabetic(r) || unicode.IsDigit(r)
+	return IsAlphabetic(r) || unicode.IsDigit(r) || unicode.IsMark(r)
 }
 
 func IsAlphabetic(r rune) bool {
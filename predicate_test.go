@@ -0,0 +1,107 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/oarkflow/expr"
+)
+
+// countingEnv returns env with items and a predicate counting its calls.
+func countingEnv(items []int, calls *int) map[string]any {
+	return map[string]any{
+		"items": items,
+		"positive": func(x int) bool {
+			*calls++
+			return x > 0
+		},
+	}
+}
+
+func TestAnyShortCircuit(t *testing.T) {
+	tests := []struct {
+		input string
+		items []int
+		want  bool
+		calls int
+	}{
+		{`any(items, positive(#))`, []int{-1, 5, 7, 9}, true, 2},
+		{`none(items, positive(#))`, []int{-1, 5, 7, 9}, false, 2},
+		{`any(items, positive(#))`, []int{-1, -2, -3}, false, 3},
+		{`none(items, positive(#))`, []int{-1, -2, -3}, true, 3},
+	}
+	for _, tt := range tests {
+		calls := 0
+		env := countingEnv(tt.items, &calls)
+		program, err := expr.Compile(tt.input, expr.Env(env))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := expr.Run(program, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != tt.want {
+			t.Errorf("%s over %v = %v, want %v", tt.input, tt.items, out, tt.want)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s over %v called predicate %d times, want %d", tt.input, tt.items, calls, tt.calls)
+		}
+	}
+}
+
+func BenchmarkAnyShortCircuit(b *testing.B) {
+	items := make([]int, 10000)
+	items[1] = 1
+	env := map[string]any{"items": items}
+	for _, bb := range []struct {
+		name  string
+		input string
+	}{
+		{"any", `any(items, # > 0)`},
+		{"filter", `len(filter(items, # > 0)) > 0`},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			program, err := expr.Compile(bb.input, expr.Env(env))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				out, err := expr.Run(program, env)
+				if err != nil || out != true {
+					b.Fatal(out, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAllShortCircuit(t *testing.T) {
+	tests := []struct {
+		items []int
+		want  bool
+		calls int
+	}{
+		{[]int{-1, 5, 7}, false, 1},
+		{[]int{5, 7, -1, 9}, false, 3},
+		{[]int{5, 7, 9}, true, 3},
+	}
+	for _, tt := range tests {
+		calls := 0
+		env := countingEnv(tt.items, &calls)
+		program, err := expr.Compile(`all(items, positive(#))`, expr.Env(env))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := expr.Run(program, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != tt.want {
+			t.Errorf("all over %v = %v, want %v", tt.items, out, tt.want)
+		}
+		if calls != tt.calls {
+			t.Errorf("all over %v called predicate %d times, want %d", tt.items, calls, tt.calls)
+		}
+	}
+}
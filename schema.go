@@ -0,0 +1,52 @@
+package expr
+
+import (
+	"reflect"
+
+	"github.com/oarkflow/expr/vm/runtime"
+)
+
+// SchemaFromStruct returns types of fields of struct v by their names in
+// expressions (expr tag, json tag, or Go name). Fields of embedded structs
+// are flattened, pointers are unwrapped, and fields of nested structs are
+// included under dotted paths, e.g. "user.name".
+func SchemaFromStruct(v any) map[string]reflect.Type {
+	schema := make(map[string]reflect.Type)
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		schemaFields(schema, t, "", map[reflect.Type]bool{})
+	}
+	return schema
+}
+
+func schemaFields(schema map[string]reflect.Type, t reflect.Type, prefix string, visited map[reflect.Type]bool) {
+	// Recursive types are described only once per path.
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct {
+			schemaFields(schema, ft, prefix, visited)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := prefix + runtime.FieldName(f, runtime.DefaultTagName)
+		schema[name] = ft
+		if ft.Kind() == reflect.Struct {
+			schemaFields(schema, ft, name+".", visited)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package expr
+
+import (
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/vm"
+)
+
+// Specialize compiles program again with values of knownEnv substituted as
+// constants, so the optimizer can fold expressions depending on them.
+// Options used to compile program should be passed again as ops.
+func Specialize(program *vm.Program, knownEnv map[string]any, ops ...Option) (*vm.Program, error) {
+	locals := &localNames{names: make(map[string]bool)}
+	if program.Node != nil {
+		ast.Walk(&program.Node, locals)
+	}
+	substitute := &substitution{values: knownEnv, locals: locals.names}
+	ops = append(append(customFunctionOptions(), ops...), Patch(substitute))
+	return Compile(program.Source.Content(), ops...)
+}
+
+// localNames collects names of variables and functions declared in expression.
+type localNames struct {
+	names map[string]bool
+}
+
+func (v *localNames) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.VariableDeclaratorNode:
+		v.names[n.Name] = true
+	case *ast.FunctionNode:
+		if n.Name != "" {
+			v.names[n.Name] = true
+		}
+		for _, param := range n.Params {
+			v.names[param] = true
+		}
+	}
+}
+
+// substitution replaces identifiers with known values.
+type substitution struct {
+	values map[string]any
+	locals map[string]bool
+}
+
+func (v *substitution) Visit(node *ast.Node) {
+	n, ok := (*node).(*ast.IdentifierNode)
+	if !ok || v.locals[n.Value] {
+		return
+	}
+	value, ok := v.values[n.Value]
+	if !ok {
+		return
+	}
+	var literal ast.Node
+	switch value := value.(type) {
+	case nil:
+		literal = &ast.NilNode{}
+	case bool:
+		literal = &ast.BoolNode{Value: value}
+	case int:
+		literal = &ast.IntegerNode{Value: value}
+	case float64:
+		literal = &ast.FloatNode{Value: value}
+	case string:
+		literal = &ast.StringNode{Value: value}
+	default:
+		literal = &ast.ConstantNode{Value: value}
+	}
+	literal.SetLocation(n.Location())
+	*node = literal
+}
@@ -0,0 +1,69 @@
+package expr
+
+import (
+	"math"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/builtin"
+	"github.com/oarkflow/expr/conf"
+)
+
+// StdlibConstants are constants available in expressions with WithStdlib.
+var StdlibConstants = map[string]any{
+	"PI":     math.Pi,
+	"E":      math.E,
+	"MaxInt": math.MaxInt,
+	"MinInt": math.MinInt,
+}
+
+// StdlibOption configures WithStdlib.
+type StdlibOption func(excluded map[string]bool)
+
+// StdlibExclude disables builtins of categories from builtin.Categories.
+func StdlibExclude(categories ...string) StdlibOption {
+	return func(excluded map[string]bool) {
+		for _, category := range categories {
+			excluded[category] = true
+		}
+	}
+}
+
+// WithStdlib makes builtins of all categories of builtin.Categories and
+// StdlibConstants available, except excluded categories. Builtins disabled
+// by default or by Sandbox stay disabled.
+func WithStdlib(opts ...StdlibOption) Option {
+	excluded := make(map[string]bool)
+	for _, opt := range opts {
+		opt(excluded)
+	}
+	return func(c *conf.Config) {
+		for category := range excluded {
+			for _, name := range builtin.Categories[category] {
+				c.Disabled[name] = true
+			}
+		}
+		c.Visitors = append(c.Visitors, &stdlibConstants{config: c})
+	}
+}
+
+// stdlibConstants replaces identifiers of StdlibConstants, which are not
+// defined in environment, with their values.
+type stdlibConstants struct {
+	config *conf.Config
+}
+
+func (v *stdlibConstants) Visit(node *ast.Node) {
+	n, ok := (*node).(*ast.IdentifierNode)
+	if !ok {
+		return
+	}
+	if _, ok := v.config.Types[n.Value]; ok {
+		return
+	}
+	switch value := StdlibConstants[n.Value].(type) {
+	case int:
+		ast.Patch(node, &ast.IntegerNode{Value: value})
+	case float64:
+		ast.Patch(node, &ast.FloatNode{Value: value})
+	}
+}
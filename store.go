@@ -0,0 +1,146 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/oarkflow/expr/conf"
+	"github.com/oarkflow/expr/vm"
+)
+
+// NamedExpr is an expression registered in ExpressionStore under Name.
+type NamedExpr struct {
+	Name string
+	Expr string
+}
+
+// ExpressionStore keeps compiled expressions by name, like rules of
+// a rule engine. Expressions may evaluate other stored expressions with
+// call("name"), which is available when env is a map or a Scope.
+type ExpressionStore struct {
+	mu       sync.RWMutex
+	options  []Option
+	programs map[string]*vm.Program
+	groups   map[string][]string
+}
+
+// callType is type of call function available in stored expressions.
+var callType = reflect.TypeOf(func(string) (any, error) { return nil, nil })
+
+// NewExpressionStore creates store compiling expressions with ops.
+func NewExpressionStore(ops ...Option) *ExpressionStore {
+	ops = append(ops, func(c *conf.Config) {
+		if c.Types != nil {
+			c.Types["call"] = conf.Tag{Type: callType}
+		}
+	})
+	return &ExpressionStore{
+		options:  ops,
+		programs: make(map[string]*vm.Program),
+		groups:   make(map[string][]string),
+	}
+}
+
+// Register compiles input and stores it as name, replacing expression
+// registered before.
+func (s *ExpressionStore) Register(name, input string) error {
+	program, err := Compile(input, s.options...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.programs[name] = program
+	return nil
+}
+
+// RegisterGroup registers all exprs and tags them with group. Nothing is
+// registered if any of exprs fails to compile.
+func (s *ExpressionStore) RegisterGroup(group string, exprs []NamedExpr) error {
+	programs := make([]*vm.Program, len(exprs))
+	for i, e := range exprs {
+		program, err := Compile(e.Expr, s.options...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+		programs[i] = program
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(exprs))
+	for i, e := range exprs {
+		s.programs[e.Name] = programs[i]
+		names[i] = e.Name
+	}
+	s.groups[group] = names
+	return nil
+}
+
+// Program returns compiled expression registered as name.
+func (s *ExpressionStore) Program(name string) (*vm.Program, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	program, ok := s.programs[name]
+	return program, ok
+}
+
+// Run evaluates expression registered as name with env.
+func (s *ExpressionStore) Run(name string, env any) (any, error) {
+	return s.run(name, env, nil)
+}
+
+// RunGroup evaluates all expressions of group with env and returns their
+// results by name. Evaluation stops at the first error.
+func (s *ExpressionStore) RunGroup(group string, env any) (map[string]any, error) {
+	s.mu.RLock()
+	names, ok := s.groups[group]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown group %s", group)
+	}
+	results := make(map[string]any, len(names))
+	for _, name := range names {
+		out, err := s.run(name, env, nil)
+		if err != nil {
+			return results, err
+		}
+		results[name] = out
+	}
+	return results, nil
+}
+
+// run evaluates name, which is called from expressions in stack.
+func (s *ExpressionStore) run(name string, env any, stack []string) (any, error) {
+	for _, caller := range stack {
+		if caller == name {
+			return nil, fmt.Errorf("%s: recursive call", name)
+		}
+	}
+	program, ok := s.Program(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown expression %s", name)
+	}
+	stack = append(stack[:len(stack):len(stack)], name)
+	call := func(callee string) (any, error) {
+		return s.run(callee, env, stack)
+	}
+	switch e := env.(type) {
+	case nil:
+		env = map[string]any{"call": call}
+	case map[string]any:
+		m := make(map[string]any, len(e)+1)
+		for k, v := range e {
+			m[k] = v
+		}
+		m["call"] = call
+		env = m
+	case *Scope:
+		env = NewScope(e, map[string]any{"call": call})
+	}
+	out, err := Run(program, env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,66 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderTemplate evaluates every {{ expression }} placeholder of tpl against
+// env and returns tpl with placeholders replaced by their results.
+// Nil results render as empty strings.
+func RenderTemplate(tpl string, env any, ops ...Option) (string, error) {
+	opts := append(customFunctionOptions(), ops...)
+
+	var out strings.Builder
+	for {
+		start := strings.Index(tpl, "{{")
+		if start < 0 {
+			out.WriteString(tpl)
+			return out.String(), nil
+		}
+		out.WriteString(tpl[:start])
+		tpl = tpl[start+2:]
+		end := placeholderEnd(tpl)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed placeholder {{%s", tpl)
+		}
+		input := tpl[:end]
+		tpl = tpl[end+2:]
+
+		program, err := Compile(input, opts...)
+		if err != nil {
+			return "", err
+		}
+		output, err := Run(program, env)
+		if err != nil {
+			return "", err
+		}
+		if output != nil {
+			out.WriteString(fmt.Sprint(output))
+		}
+	}
+}
+
+// placeholderEnd returns index of "}}" closing placeholder, skipping
+// string literals and braces of map literals inside of expression.
+func placeholderEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\'', '`':
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 && i+1 < len(s) && s[i+1] == '}' {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
@@ -0,0 +1,63 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+// Benchmark compiles src once and runs it with env b.N times, reporting
+// allocations. Call it from BenchmarkXxx functions.
+func Benchmark(src string, env any, b *testing.B) {
+	b.Helper()
+	var ops []Option
+	if env != nil {
+		ops = append(ops, Env(env))
+	}
+	program, err := Compile(src, ops...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = Run(program, env)
+	}
+	b.StopTimer()
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// FuzzTest registers fuzz target compiling mutations of src with
+// environment seed, failing if compilation panics instead of returning
+// an error. Call it from FuzzXxx functions.
+func FuzzTest(src string, seed any, f *testing.F) {
+	f.Helper()
+	f.Add(src)
+	for _, s := range []string{
+		"",
+		"+",
+		"(",
+		`"`,
+		"a.",
+		"[1, 2",
+		"{a: }",
+		"1 ? 2",
+		strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100),
+		strings.Repeat("-", 100) + "1",
+	} {
+		f.Add(s)
+	}
+	ops := customFunctionOptions()
+	if seed != nil {
+		ops = append(ops, Env(seed))
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("panic on %q: %v", input, r)
+			}
+		}()
+		_, _ = Compile(input, ops...)
+	})
+}
@@ -0,0 +1,40 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oarkflow/expr/vm"
+)
+
+// TraceEvent is event of evaluation emitted by Trace.
+type TraceEvent = vm.TraceEvent
+
+// EventType is type of TraceEvent.
+type EventType = vm.EventType
+
+const (
+	EventEnter = vm.EventEnter
+	EventExit  = vm.EventExit
+	EventError = vm.EventError
+)
+
+// Trace runs program in background and streams events of evaluated nodes.
+// The channel is closed when evaluation completes; if ctx is done earlier,
+// evaluation stops and remaining events are dropped.
+func Trace(ctx context.Context, program *vm.Program, env any) (<-chan TraceEvent, error) {
+	if program == nil {
+		return nil, fmt.Errorf("program is nil")
+	}
+	events := make(chan TraceEvent)
+	go func() {
+		defer close(events)
+		_, _ = vm.RunTrace(ctx, program, env, func(event vm.TraceEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return events, nil
+}
@@ -0,0 +1,83 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EvalSlice evaluates input and converts every element of resulting array
+// to T.
+func EvalSlice[T any](input string, env any) ([]T, error) {
+	output, err := Eval(input, env)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(output)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected array, got %T", output)
+	}
+	result := make([]T, v.Len())
+	for i := range result {
+		if err := convertTo(v.Index(i), &result[i]); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// EvalMap evaluates input and converts every key of resulting map to K and
+// every value to V.
+func EvalMap[K comparable, V any](input string, env any) (map[K]V, error) {
+	output, err := Eval(input, env)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(output)
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf("expected map, got %T", output)
+	}
+	result := make(map[K]V, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var key K
+		var value V
+		if err := convertTo(iter.Key(), &key); err != nil {
+			return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+		}
+		if err := convertTo(iter.Value(), &value); err != nil {
+			return nil, fmt.Errorf("value of key %v: %w", iter.Key(), err)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// convertTo stores v into dst, converting it to type of dst if needed.
+func convertTo[T any](v reflect.Value, dst *T) error {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	t := reflect.TypeOf(dst).Elem()
+	switch {
+	case v.Type().AssignableTo(t):
+		reflect.ValueOf(dst).Elem().Set(v)
+	case isNumber(v.Kind()) && isNumber(t.Kind()):
+		reflect.ValueOf(dst).Elem().Set(v.Convert(t))
+	default:
+		return fmt.Errorf("cannot convert %v to %v", v.Type(), t)
+	}
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Float64
+}
@@ -0,0 +1,90 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/oarkflow/expr/ast"
+	"github.com/oarkflow/expr/conf"
+	"github.com/oarkflow/expr/file"
+)
+
+// Severity is importance of Warning.
+type Severity int
+
+const (
+	Low Severity = iota
+	Medium
+	High
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Low:
+		return "low"
+	case Medium:
+		return "medium"
+	case High:
+		return "high"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Warning is a problem found by Validate which does not prevent
+// compilation, but likely is a mistake.
+type Warning struct {
+	Severity Severity
+	Message  string
+	Location file.Location
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%v: %s (%d:%d)", w.Severity, w.Message, w.Location.Line, w.Location.Column+1)
+}
+
+// Validate parses and type checks input and returns warnings of static
+// analysis. Names of schema, like ones returned by SchemaFromStruct, are
+// treated as variables of environment; dotted paths of nested fields are
+// ignored. Schema may be nil if environment is given with Env option.
+func Validate(input string, schema map[string]reflect.Type, ops ...Option) ([]Warning, error) {
+	s := &shadowing{}
+	ops = append(ops, func(c *conf.Config) {
+		if c.Types == nil {
+			c.Types = make(conf.TypesTable)
+		}
+		for name, t := range schema {
+			if !strings.Contains(name, ".") {
+				c.Types[name] = conf.Tag{Type: t}
+			}
+		}
+		if schema != nil {
+			c.Strict = true
+		}
+		s.types = c.Types
+		c.Visitors = append(c.Visitors, s)
+	})
+	_, _, err := check(context.Background(), input, ops)
+	return s.warnings, err
+}
+
+// shadowing finds let declarations hiding values of environment. Shadowed
+// names are removed from types, so the checker accepts declarations.
+type shadowing struct {
+	types    conf.TypesTable
+	warnings []Warning
+}
+
+func (s *shadowing) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.VariableDeclaratorNode); ok {
+		if _, ok := s.types[n.Name]; ok {
+			delete(s.types, n.Name)
+			s.warnings = append(s.warnings, Warning{
+				Severity: Low,
+				Message:  fmt.Sprintf("local variable '%s' shadows environment key", n.Name),
+				Location: n.Location(),
+			})
+		}
+	}
+}
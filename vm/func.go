@@ -0,0 +1,166 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// MaxCallDepth limits nesting of calls to functions defined in expressions.
+var MaxCallDepth = 10000
+
+// FunctionInfo describes a function defined in an expression.
+// Body of the function is compiled into the same program and
+// starts at Entry.
+type FunctionInfo struct {
+	Name   string
+	Entry  int
+	Self   int   // variable holding the function itself, -1 if anonymous
+	Params []int // variables holding the arguments
+}
+
+// Func is a function value created by evaluating a func expression.
+type Func struct {
+	*FunctionInfo
+	program   *Program
+	env       any
+	variables []any    // variables captured at creation
+	scopes    []*Scope // scopes of predicates enclosing creation
+	depth     int
+	bound     []any // arguments of partial application
+	ctx       context.Context
+	calls     *int // function calls of evaluation which created the function
+}
+
+// Call evaluates the function with given arguments.
+func (f *Func) Call(args ...any) (out any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	return f.call(args, f.depth), nil
+}
+
+func (f *Func) call(args []any, depth int) any {
+	if len(f.bound) > 0 {
+		args = append(append([]any{}, f.bound...), args...)
+	}
+	if len(args) < len(f.Params) {
+		// Not enough arguments: return a function waiting for the rest.
+		partial := *f
+		partial.bound = args
+		return &partial
+	}
+	if len(args) > len(f.Params) {
+		panic(fmt.Sprintf("%v expects %d arguments (got %d)", f.name(), len(f.Params), len(args)))
+	}
+	if depth >= MaxCallDepth {
+		panic("maximum call depth exceeded")
+	}
+
+	vm := &VM{
+		ip:           f.Entry,
+		depth:        depth + 1,
+		memoryBudget: MemoryBudget,
+		variables:    make([]any, len(f.variables)),
+		scopes:       append([]*Scope{}, f.scopes...),
+		ctx:          f.ctx,
+		calls:        f.calls,
+	}
+	copy(vm.variables, f.variables)
+	if f.Self >= 0 {
+		self := *f
+		self.bound = nil
+		vm.variables[f.Self] = &self
+	}
+	for i, p := range f.Params {
+		vm.variables[p] = args[i]
+	}
+	return vm.run(f.program, f.env)
+}
+
+// arity returns number of arguments f still expects.
+func (f *Func) arity() int {
+	return len(f.Params) - len(f.bound)
+}
+
+func (f *Func) name() string {
+	if f.Name == "" {
+		return "func"
+	}
+	return f.Name
+}
+
+func (f *Func) String() string {
+	return fmt.Sprintf("%v/%d", f.name(), f.arity())
+}
+
+// makeFunc converts f into a Go function of type t.
+func (f *Func) makeFunc(t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		args := make([]any, 0, len(in))
+		for i, arg := range in {
+			if t.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					args = append(args, arg.Index(j).Interface())
+				}
+			} else {
+				args = append(args, arg.Interface())
+			}
+		}
+
+		out, err := f.Call(args...)
+		withError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+		if err != nil && !withError {
+			panic(err)
+		}
+
+		results := make([]reflect.Value, t.NumOut())
+		for i := range results {
+			rt := t.Out(i)
+			switch {
+			case rt == errorType && i == len(results)-1:
+				results[i] = reflect.Zero(rt)
+				if err != nil {
+					results[i] = reflect.ValueOf(&err).Elem()
+				}
+			case out == nil:
+				results[i] = reflect.Zero(rt)
+			default:
+				v := reflect.ValueOf(out)
+				if !v.Type().AssignableTo(rt) && v.Type().ConvertibleTo(rt) {
+					v = v.Convert(rt)
+				}
+				results[i] = v
+			}
+		}
+		return results
+	})
+}
+
+// Native returns f as a Go function. Functions with a single parameter
+// become func(any) (any, error), others func(...any) (any, error).
+func (f *Func) Native() any {
+	if f.arity() == 1 {
+		return func(arg any) (any, error) {
+			return f.Call(arg)
+		}
+	}
+	return func(args ...any) (any, error) {
+		return f.Call(args...)
+	}
+}
+
+// native converts functions defined in expression into Go functions
+// before passing them into functions registered with expr.Function.
+func native(v any) any {
+	if f, ok := v.(*Func); ok {
+		return f.Native()
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package vm
+
+import (
+	"hash/maphash"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Memoize returns copy of program which caches results of runs for ttl.
+// Runs with env of the same content share a result. Content is hashed
+// through pointers, so mutating a value referenced by env is seen as a
+// new env. Errors are not cached.
+func Memoize(program *Program, ttl time.Duration) *Program {
+	memoized := *program
+	memoized.memo = &memo{
+		ttl:     ttl,
+		seed:    maphash.MakeSeed(),
+		entries: make(map[uint64]memoEntry),
+	}
+	return &memoized
+}
+
+type memo struct {
+	ttl       time.Duration
+	seed      maphash.Seed
+	mu        sync.Mutex
+	entries   map[uint64]memoEntry
+	lastSweep time.Time
+}
+
+type memoEntry struct {
+	output  any
+	expires time.Time
+}
+
+func (m *memo) run(env any, fn func() (any, error)) (any, error) {
+	key := m.hash(env)
+	now := time.Now()
+
+	m.mu.Lock()
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		m.mu.Unlock()
+		return e.output, nil
+	}
+	m.mu.Unlock()
+
+	output, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > m.ttl {
+		for k, e := range m.entries {
+			if !now.Before(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.entries[key] = memoEntry{output: output, expires: now.Add(m.ttl)}
+	return output, nil
+}
+
+// hash returns hash of content of env.
+func (m *memo) hash(env any) uint64 {
+	var h maphash.Hash
+	h.SetSeed(m.seed)
+	m.write(&h, reflect.ValueOf(env), make(map[uintptr]bool))
+	return h.Sum64()
+}
+
+// write writes content of v into h, following pointers. Values already
+// being written are in visiting, so cyclic data terminates.
+func (m *memo) write(h *maphash.Hash, v reflect.Value, visiting map[uintptr]bool) {
+	if !v.IsValid() {
+		_ = h.WriteByte(0)
+		return
+	}
+	_, _ = h.WriteString(v.Type().String())
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			_ = h.WriteByte(1)
+		} else {
+			_ = h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint(h, math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		writeUint(h, math.Float64bits(real(v.Complex())))
+		writeUint(h, math.Float64bits(imag(v.Complex())))
+	case reflect.String:
+		_, _ = h.WriteString(v.String())
+		_ = h.WriteByte(0)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			m.write(h, v.Index(i), visiting)
+		}
+	case reflect.Slice:
+		writeUint(h, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			m.write(h, v.Index(i), visiting)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			m.write(h, v.Field(i), visiting)
+		}
+	case reflect.Interface:
+		m.write(h, v.Elem(), visiting)
+	case reflect.Pointer:
+		if v.IsNil() || visiting[v.Pointer()] {
+			writeUint(h, uint64(v.Pointer()))
+			return
+		}
+		visiting[v.Pointer()] = true
+		m.write(h, v.Elem(), visiting)
+		delete(visiting, v.Pointer())
+	case reflect.Map:
+		if v.IsNil() || visiting[v.Pointer()] {
+			writeUint(h, uint64(v.Pointer()))
+			return
+		}
+		visiting[v.Pointer()] = true
+		// Entries are hashed separately and summed, so order of
+		// iteration does not matter.
+		var sum uint64
+		iter := v.MapRange()
+		for iter.Next() {
+			var entry maphash.Hash
+			entry.SetSeed(m.seed)
+			m.write(&entry, iter.Key(), visiting)
+			m.write(&entry, iter.Value(), visiting)
+			sum += entry.Sum64()
+		}
+		writeUint(h, uint64(v.Len()))
+		writeUint(h, sum)
+		delete(visiting, v.Pointer())
+	default:
+		// Functions, channels and unsafe pointers are compared by identity.
+		writeUint(h, uint64(v.Pointer()))
+	}
+}
+
+func writeUint(h *maphash.Hash, x uint64) {
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(x >> (8 * i))
+	}
+	_, _ = h.Write(b[:])
+}
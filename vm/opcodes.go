@@ -50,6 +50,9 @@ const (
 	OpContains
 	OpStartsWith
 	OpEndsWith
+	OpEqualFold
+	OpSemVer
+	OpLoadContext
 	OpSlice
 	OpCall
 	OpCall0
@@ -60,8 +63,10 @@ const (
 	OpCallFast
 	OpCallTyped
 	OpCallBuiltin1
+	OpCallSpread
 	OpArray
 	OpMap
+	OpOrderedMap
 	OpLen
 	OpCast
 	OpDeref
@@ -73,11 +78,14 @@ const (
 	OpGetCount
 	OpGetLen
 	OpGetGroupBy
+	OpGetOrderedGroupBy
 	OpGetAcc
 	OpPointer
 	OpThrow
 	OpGroupBy
 	OpSetAcc
+	OpFunction
+	OpReturn
 	OpBegin
 	OpEnd // This opcode must be at the end of this list.
 )
@@ -6,8 +6,10 @@ import (
 	"io"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/oarkflow/expr/ast"
 	"github.com/oarkflow/expr/builtin"
@@ -25,12 +27,242 @@ type Program struct {
 	Arguments []int
 	Functions []Function
 	DebugInfo map[string]string
+	Spans     []Span // instructions compiled from nodes, outer nodes first
+
+	// OnIdentifierAccess is called when identifier is read from environment.
+	// For struct fields accessed directly name is a dotted path, like "user.name".
+	OnIdentifierAccess func(name string, value any)
+
+	// OnPanic is called with value recovered from panic of a function
+	// call and the call node.
+	OnPanic func(recovered any, node ast.Node)
+
+	// IdentifierResolver replaces lookup of identifiers in environment.
+	IdentifierResolver func(name string, env any) (any, bool)
+
+	// TagName is struct tag with names of fields, default is runtime.DefaultTagName.
+	TagName string
+
+	// TypeConverter computes result of operator for operands it cannot
+	// be applied to, instead of failing.
+	TypeConverter func(a, b any, operator string) (any, error)
+
+	// StrictMode makes operators fail on operands of mismatched types
+	// instead of converting them.
+	StrictMode bool
+
+	// FunctionCallLimit is maximum number of function calls during
+	// single evaluation, zero means unlimited.
+	FunctionCallLimit int
+
+	// TimeLayout is layout of strings compared with time.Time values,
+	// default is time.RFC3339.
+	TimeLayout string
+
+	// LocationPrefix formats runtime errors as "line 3, col 7: message".
+	LocationPrefix bool
+
+	memo *memo
+}
+
+// Span is range of instructions [Start, End) compiled from Node.
+type Span struct {
+	Node       ast.Node
+	Start, End int
 }
 
 func (program *Program) Eval(param any) (any, error) {
 	return Run(program, param)
 }
 
+// Run runs program with env, which can be a map, a struct or *runtime.Environment.
+func (program *Program) Run(env any) (any, error) {
+	return Run(program, env)
+}
+
+// operate applies operator fn to a and b. If fn fails and TypeConverter
+// is set, result of TypeConverter is used instead.
+func operate[T any](program *Program, operator string, a, b any, fn func(a, b any) T) any {
+	if program.TypeConverter == nil {
+		return fn(a, b)
+	}
+	return convert(program, operator, a, b, fn)
+}
+
+func convert[T any](program *Program, operator string, a, b any, fn func(a, b any) T) (out any) {
+	defer func() {
+		if r := recover(); r != nil {
+			var err error
+			out, err = program.TypeConverter(a, b, operator)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}()
+	return fn(a, b)
+}
+
+// strict panics in strict mode if operator would convert a or b.
+func (program *Program) strict(operator string, a, b any) {
+	if program.StrictMode {
+		if err := runtime.StrictOperands(operator, reflect.TypeOf(a), reflect.TypeOf(b)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// fetch is runtime.Fetch honoring program.TagName.
+func (program *Program) fetch(from, i any) any {
+	if program.TagName == "" {
+		return runtime.Fetch(from, i)
+	}
+	return runtime.FetchByTag(from, i, program.TagName)
+}
+
+// OutputType returns type of program result inferred during type checking,
+// or interface type if it is unknown.
+func (program *Program) OutputType() reflect.Type {
+	// Result of AsInt, AsInt64 and AsFloat64 is converted by final OpCast.
+	if n := len(program.Bytecode); n > 0 && program.Bytecode[n-1] == OpCast {
+		switch program.Arguments[n-1] {
+		case 0:
+			return reflect.TypeOf(0)
+		case 1:
+			return reflect.TypeOf(int64(0))
+		case 2:
+			return reflect.TypeOf(float64(0))
+		}
+	}
+	if program.Node == nil || program.Node.Type() == nil {
+		return reflect.TypeOf((*any)(nil)).Elem()
+	}
+	return program.Node.Type()
+}
+
+// InputTypes returns types of identifiers read by program, inferred during
+// type checking, including variables declared with let. Parameters of
+// functions declared in expression are not included.
+func (program *Program) InputTypes() map[string]reflect.Type {
+	v := program.identifiers()
+	types := make(map[string]reflect.Type)
+	for name, t := range v.types {
+		if t != nil && !v.params[name] {
+			types[name] = t
+		}
+	}
+	return types
+}
+
+// Dependencies returns sorted names of environment values read by program.
+func (program *Program) Dependencies() []string {
+	v := program.identifiers()
+	var names []string
+	for name := range v.types {
+		if !v.params[name] && !v.lets[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (program *Program) identifiers() *identifiers {
+	v := &identifiers{
+		types:  make(map[string]reflect.Type),
+		lets:   make(map[string]bool),
+		params: make(map[string]bool),
+	}
+	if program.Node != nil {
+		ast.Walk(&program.Node, v)
+	}
+	return v
+}
+
+type identifiers struct {
+	types  map[string]reflect.Type
+	lets   map[string]bool
+	params map[string]bool
+}
+
+func (v *identifiers) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		if n.Value != "$env" && n.Func == nil {
+			if _, ok := v.types[n.Value]; !ok || n.Type() != nil {
+				v.types[n.Value] = n.Type()
+			}
+		}
+	case *ast.MemberNode:
+		if id, ok := n.Node.(*ast.IdentifierNode); ok && id.Value == "$env" {
+			if name, ok := n.Property.(*ast.StringNode); ok {
+				if _, ok := v.types[name.Value]; !ok {
+					v.types[name.Value] = n.Type()
+				}
+			}
+		}
+	case *ast.VariableDeclaratorNode:
+		v.types[n.Name] = n.Value.Type()
+		v.lets[n.Name] = true
+	case *ast.FunctionNode:
+		for _, param := range n.Params {
+			v.params[param] = true
+		}
+	}
+}
+
+// in is runtime.In honoring program.TagName.
+func (program *Program) in(needle, array any) bool {
+	if program.TagName == "" {
+		return runtime.In(needle, array)
+	}
+	return runtime.InByTag(needle, array, program.TagName)
+}
+
+// parseTimes parses string operand compared with time.Time operand
+// using program.TimeLayout. Panics if string is not a valid time.
+func (program *Program) parseTimes(a, b any) (any, any) {
+	a, b, err := program.tryParseTimes(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return a, b
+}
+
+// equal compares a and b like runtime.Equal, parsing string compared
+// with time.Time. Strings which are not valid times are not equal to any
+// time.
+func (program *Program) equal(a, b any) bool {
+	a, b, err := program.tryParseTimes(a, b)
+	if err != nil {
+		return false
+	}
+	return runtime.Equal(a, b)
+}
+
+func (program *Program) tryParseTimes(a, b any) (any, any, error) {
+	switch x := a.(type) {
+	case time.Time:
+		if y, ok := b.(string); ok {
+			t, err := program.parseTime(y)
+			return x, t, err
+		}
+	case string:
+		if y, ok := b.(time.Time); ok {
+			t, err := program.parseTime(x)
+			return t, y, err
+		}
+	}
+	return a, b, nil
+}
+
+func (program *Program) parseTime(s string) (time.Time, error) {
+	layout := program.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return time.Parse(layout, s)
+}
+
 func (program *Program) Disassemble() string {
 	var buf bytes.Buffer
 	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
@@ -78,6 +310,9 @@ func (program *Program) Opcodes(w io.Writer) {
 			if method, ok := c.(*runtime.Method); ok {
 				c = fmt.Sprintf("{%v %v}", method.Name, method.Index)
 			}
+			if fn, ok := c.(*FunctionInfo); ok {
+				c = fmt.Sprintf("{%v %v}", fn.Name, fn.Entry)
+			}
 			_, _ = fmt.Fprintf(w, "%v\t%v\t<%v>\t%v\n", pp, label, arg, c)
 		}
 		builtinArg := func(label string) {
@@ -226,6 +461,15 @@ func (program *Program) Opcodes(w io.Writer) {
 		case OpEndsWith:
 			code("OpEndsWith")
 
+		case OpEqualFold:
+			code("OpEqualFold")
+
+		case OpLoadContext:
+			code("OpLoadContext")
+
+		case OpSemVer:
+			_, _ = fmt.Fprintf(w, "%v\t%v\t<%v>\t%v\n", pp, "OpSemVer", arg, runtime.SemVerOperators[arg>>1])
+
 		case OpSlice:
 			code("OpSlice")
 
@@ -257,12 +501,18 @@ func (program *Program) Opcodes(w io.Writer) {
 		case OpCallBuiltin1:
 			builtinArg("OpCallBuiltin1")
 
+		case OpCallSpread:
+			argument("OpCallSpread")
+
 		case OpArray:
 			code("OpArray")
 
 		case OpMap:
 			code("OpMap")
 
+		case OpOrderedMap:
+			code("OpOrderedMap")
+
 		case OpLen:
 			code("OpLen")
 
@@ -296,6 +546,9 @@ func (program *Program) Opcodes(w io.Writer) {
 		case OpGetGroupBy:
 			code("OpGetGroupBy")
 
+		case OpGetOrderedGroupBy:
+			code("OpGetOrderedGroupBy")
+
 		case OpGetAcc:
 			code("OpGetAcc")
 
@@ -311,6 +564,12 @@ func (program *Program) Opcodes(w io.Writer) {
 		case OpSetAcc:
 			code("OpSetAcc")
 
+		case OpFunction:
+			constant("OpFunction")
+
+		case OpReturn:
+			code("OpReturn")
+
 		case OpBegin:
 			code("OpBegin")
 
@@ -0,0 +1,45 @@
+package runtime
+
+// Environment is a chain of bindings with lexical scoping: names not bound
+// in the environment are looked up in its parent.
+type Environment struct {
+	parent   *Environment
+	bindings map[string]any
+}
+
+// NewEnvironment creates environment inheriting from parent, which may be nil.
+func NewEnvironment(parent *Environment, bindings map[string]any) *Environment {
+	return &Environment{parent: parent, bindings: bindings}
+}
+
+// Parent returns parent of the environment, or nil for root environment.
+func (e *Environment) Parent() *Environment {
+	return e.parent
+}
+
+// Get looks up name through the chain of environments.
+func (e *Environment) Get(name string) (any, bool) {
+	for ; e != nil; e = e.parent {
+		if v, ok := e.bindings[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Lookup is Get.
+func (e *Environment) Lookup(name string) (any, bool) {
+	return e.Get(name)
+}
+
+// Map returns all visible bindings, flattened into a new map.
+func (e *Environment) Map() map[string]any {
+	if e == nil {
+		return map[string]any{}
+	}
+	m := e.parent.Map()
+	for k, v := range e.bindings {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// OrderedMap is a map built from map literal when conf.Config.OrderedMaps
+// is set. Keys are iterated in order of declaration.
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewOrderedMap returns empty map with capacity for size keys.
+func NewOrderedMap(size int) *OrderedMap {
+	return &OrderedMap{
+		keys:   make([]string, 0, size),
+		values: make(map[string]any, size),
+	}
+}
+
+// Set sets value of key. New keys are added to the end.
+func (m *OrderedMap) Set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns value of key.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Len returns number of keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns keys in order of declaration.
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+// Values returns values in order of declaration of their keys.
+func (m *OrderedMap) Values() []any {
+	values := make([]any, len(m.keys))
+	for i, key := range m.keys {
+		values[i] = m.values[key]
+	}
+	return values
+}
+
+// Map returns copy of m as native map.
+func (m *OrderedMap) Map() map[string]any {
+	out := make(map[string]any, len(m.keys))
+	for key, value := range m.values {
+		out[key] = value
+	}
+	return out
+}
+
+// MarshalJSON encodes m as JSON object keeping order of keys.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -7,9 +7,48 @@ import (
 	"math"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
+// DefaultTagName is struct tag holding names of fields in expressions.
+// Tag "json" is used as a fallback.
+const DefaultTagName = "expr"
+
+// FieldName returns name of struct field in expressions: name from tag,
+// name from json tag, or Go name of the field.
+func FieldName(field reflect.StructField, tag string) string {
+	if tag == "" {
+		tag = DefaultTagName
+	}
+	for _, tag := range []string{tag, "json"} {
+		if value, ok := field.Tag.Lookup(tag); ok {
+			if name, _, _ := strings.Cut(value, ","); name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
 func Fetch(from, i any) any {
+	return FetchByTag(from, i, DefaultTagName)
+}
+
+// FetchByTag is Fetch which finds struct fields by names from given tag.
+func FetchByTag(from, i any, tag string) any {
+	if e, ok := from.(*Environment); ok {
+		if name, ok := i.(string); ok {
+			value, _ := e.Get(name)
+			return value
+		}
+	}
+	if m, ok := from.(*OrderedMap); ok {
+		if key, ok := i.(string); ok {
+			value, _ := m.Get(key)
+			return value
+		}
+	}
+
 	v := reflect.ValueOf(from)
 	kind := v.Kind()
 	if kind == reflect.Invalid {
@@ -65,18 +104,30 @@ func Fetch(from, i any) any {
 		fieldName := i.(string)
 		value := v.FieldByNameFunc(func(name string) bool {
 			field, _ := v.Type().FieldByName(name)
-			if field.Tag.Get("expr") == fieldName {
-				return true
-			}
-			return name == fieldName
+			return FieldName(field, tag) == fieldName || name == fieldName
 		})
 		if value.IsValid() {
 			return value.Interface()
 		}
 	}
+
+	// Methods with pointer receivers are called on a copy of the value.
+	if methodName, ok := i.(string); ok && v.Kind() != reflect.Ptr {
+		if _, ok := reflect.PointerTo(v.Type()).MethodByName(methodName); ok {
+			ptr := reflect.New(v.Type())
+			ptr.Elem().Set(v)
+			return ptr.MethodByName(methodName).Interface()
+		}
+	}
 	panic(fmt.Sprintf("cannot fetch %v from %T", i, from))
 }
 
+// GroupEntry is a single group produced by groupBy when groups are ordered.
+type GroupEntry struct {
+	Key    any
+	Values []any
+}
+
 type Field struct {
 	Index []int
 	Path  []string
@@ -215,9 +266,30 @@ func Slice(array, from, to any) any {
 }
 
 func In(needle any, array any) bool {
+	return InByTag(needle, array, DefaultTagName)
+}
+
+// InByTag is In which finds struct fields by names from given tag.
+func InByTag(needle any, array any, tag string) bool {
 	if array == nil {
 		return false
 	}
+	if e, ok := array.(*Environment); ok {
+		name, ok := needle.(string)
+		if !ok {
+			return false
+		}
+		_, ok = e.Get(name)
+		return ok
+	}
+	if m, ok := array.(*OrderedMap); ok {
+		key, ok := needle.(string)
+		if !ok {
+			return false
+		}
+		_, ok = m.Get(key)
+		return ok
+	}
 	v := reflect.ValueOf(array)
 
 	switch v.Kind() {
@@ -250,16 +322,17 @@ func In(needle any, array any) bool {
 		if !n.IsValid() || n.Kind() != reflect.String {
 			panic(fmt.Sprintf("cannot use %T as field name of %T", needle, array))
 		}
-		value := v.FieldByName(n.String())
-		if value.IsValid() {
-			return true
-		}
-		return false
+		fieldName := n.String()
+		_, ok := v.Type().FieldByNameFunc(func(name string) bool {
+			field, _ := v.Type().FieldByName(name)
+			return FieldName(field, tag) == fieldName || name == fieldName
+		})
+		return ok
 
 	case reflect.Ptr:
 		value := v.Elem()
 		if value.IsValid() {
-			return In(needle, value.Interface())
+			return InByTag(needle, value.Interface(), tag)
 		}
 		return false
 	}
@@ -268,6 +341,9 @@ func In(needle any, array any) bool {
 }
 
 func Len(a any) int {
+	if m, ok := a.(*OrderedMap); ok {
+		return m.Len()
+	}
 	v := reflect.ValueOf(a)
 	switch v.Kind() {
 	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
@@ -308,6 +384,17 @@ func Negate(i any) any {
 	}
 }
 
+// EqualFold reports whether strings a and b are equal under Unicode case
+// folding.
+func EqualFold(a, b any) bool {
+	x, ok := a.(string)
+	y, ok2 := b.(string)
+	if !ok || !ok2 {
+		panic(fmt.Sprintf("invalid operation: %T equalsIgnoreCase %T", a, b))
+	}
+	return strings.EqualFold(x, y)
+}
+
 func Exponent(a, b any) float64 {
 	return math.Pow(ToFloat64(a), ToFloat64(b))
 }
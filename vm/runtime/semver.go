@@ -0,0 +1,125 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a parsed semantic version.
+type SemVer struct {
+	Major, Minor, Patch int
+	PreRelease          []string
+}
+
+// ParseSemVer parses version string like "v1.2.3-rc.1+build".
+// In non-strict mode missing minor and patch components default to zero,
+// pre-release and build metadata are dropped. In strict mode all three
+// components are required and the pre-release tag is kept.
+func ParseSemVer(s string, strict bool) SemVer {
+	v := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i] // Build metadata never affects precedence.
+	}
+	var pre string
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 || (strict && len(parts) != 3) {
+		panic(fmt.Sprintf("invalid semantic version %q", s))
+	}
+	var out SemVer
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			panic(fmt.Sprintf("invalid semantic version %q", s))
+		}
+		switch i {
+		case 0:
+			out.Major = n
+		case 1:
+			out.Minor = n
+		case 2:
+			out.Patch = n
+		}
+	}
+	if strict && pre != "" {
+		out.PreRelease = strings.Split(pre, ".")
+	}
+	return out
+}
+
+// CompareSemVer returns -1, 0 or 1 if a is less than, equal to or greater than b.
+func CompareSemVer(a, b string, strict bool) int {
+	x, y := ParseSemVer(a, strict), ParseSemVer(b, strict)
+	if c := compareInt(x.Major, y.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(x.Minor, y.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(x.Patch, y.Patch); c != 0 {
+		return c
+	}
+	// A version without pre-release has higher precedence.
+	switch {
+	case len(x.PreRelease) == 0 && len(y.PreRelease) == 0:
+		return 0
+	case len(x.PreRelease) == 0:
+		return 1
+	case len(y.PreRelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(x.PreRelease) && i < len(y.PreRelease); i++ {
+		if c := comparePreRelease(x.PreRelease[i], y.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(x.PreRelease), len(y.PreRelease))
+}
+
+func comparePreRelease(a, b string) int {
+	n, errA := strconv.Atoi(a)
+	m, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInt(n, m)
+	case errA == nil:
+		return -1 // Numeric identifiers have lower precedence.
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// SemVerOperators lists semantic version operators in the order of OpSemVer arguments.
+var SemVerOperators = []string{"semverEq", "semverGt", "semverGte", "semverLt", "semverLte"}
+
+// SemVerOp applies semantic version operator with the given index in SemVerOperators.
+func SemVerOp(op int, a, b string, strict bool) bool {
+	c := CompareSemVer(a, b, strict)
+	switch SemVerOperators[op] {
+	case "semverEq":
+		return c == 0
+	case "semverGt":
+		return c > 0
+	case "semverGte":
+		return c >= 0
+	case "semverLt":
+		return c < 0
+	case "semverLte":
+		return c <= 0
+	}
+	panic(fmt.Sprintf("unknown semantic version operator %v", op))
+}
@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// StrictOperands reports error if operator would implicitly convert
+// operands of types a and b. Numbers of different kinds may be mixed in
+// arithmetic, but not compared; nil may be compared with anything.
+func StrictOperands(operator string, a, b reflect.Type) error {
+	ca, cb := typeClass(a), typeClass(b)
+	if ca == "" || cb == "" {
+		return nil
+	}
+	ok := ca == cb
+	switch operator {
+	case "+", "-", "*", "/", "%", "**":
+		switch {
+		case numeric(ca) && numeric(cb):
+			ok = true
+		case operator == "+" && ca == "string" && cb == "string":
+			ok = true
+		case ca == "time" && (cb == "duration" || operator == "-" && cb == "time"):
+			ok = true
+		case operator == "+" && ca == "duration" && cb == "time":
+			ok = true
+		default:
+			ok = false
+		}
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf(`invalid operation: %v (mismatched types %v and %v)`, operator, a, b)
+	}
+	return nil
+}
+
+// typeClass groups types which are compatible in strict mode, nil type
+// and interfaces have no class.
+func typeClass(t reflect.Type) string {
+	if t == nil {
+		return ""
+	}
+	switch t {
+	case timeType:
+		return "time"
+	case durationType:
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return ""
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	}
+	return t.Kind().String()
+}
+
+func numeric(class string) bool {
+	return class == "int" || class == "float" || class == "duration"
+}
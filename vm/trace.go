@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oarkflow/expr/ast"
+)
+
+// EventType is type of TraceEvent.
+type EventType int
+
+const (
+	EventEnter EventType = iota // evaluation of node starts
+	EventExit                   // node is evaluated, Value is its result
+	EventError                  // evaluation failed in node, Value is the error
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventEnter:
+		return "enter"
+	case EventExit:
+		return "exit"
+	case EventError:
+		return "error"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// TraceEvent is emitted by RunTrace while evaluating nodes of program.
+// Nodes evaluated many times, like predicates of builtins, emit events
+// on every evaluation. Depth is nesting of node in evaluated nodes.
+type TraceEvent struct {
+	Type  EventType
+	Node  ast.Node
+	Value any
+	Depth int
+}
+
+// RunTrace runs program calling fn with events of evaluated nodes.
+// Bodies of functions declared in expression are not traced. Evaluation
+// stops with error of ctx when ctx is done.
+func RunTrace(ctx context.Context, program *Program, env any, fn func(TraceEvent)) (any, error) {
+	if program == nil {
+		return nil, fmt.Errorf("program is nil")
+	}
+
+	t := &tracer{emit: fn, starts: make(map[int][]*Span)}
+	for i := range program.Spans {
+		span := &program.Spans[i]
+		if span.Start < span.End {
+			t.starts[span.Start] = append(t.starts[span.Start], span)
+		}
+	}
+	vm := VM{ctx: ctx, tracer: t}
+	return vm.execute(program, env)
+}
+
+type tracer struct {
+	emit   func(TraceEvent)
+	starts map[int][]*Span // spans by first instruction, outer first
+	open   []*Span         // spans being evaluated, innermost last
+}
+
+// step emits events for instruction ip about to be executed.
+func (vm *VM) traceStep(ip int) {
+	if err := vm.ctx.Err(); err != nil {
+		panic(err)
+	}
+	t := vm.tracer
+	for len(t.open) > 0 {
+		span := t.open[len(t.open)-1]
+		if span.Start <= ip && ip < span.End {
+			break
+		}
+		vm.traceExit(span, ip == span.End)
+	}
+	for _, span := range t.starts[ip] {
+		t.open = append(t.open, span)
+		t.emit(TraceEvent{Type: EventEnter, Node: span.Node, Depth: len(t.open) - 1})
+	}
+}
+
+// traceExit closes innermost span. Result of span is on top of the stack
+// only if its last instruction was executed.
+func (vm *VM) traceExit(span *Span, done bool) {
+	t := vm.tracer
+	t.open = t.open[:len(t.open)-1]
+	var value any
+	if done && len(vm.stack) > 0 {
+		value = vm.current()
+	}
+	t.emit(TraceEvent{Type: EventExit, Node: span.Node, Value: value, Depth: len(t.open)})
+}
+
+// traceEnd closes spans left open at the end of evaluation.
+func (vm *VM) traceEnd() {
+	for len(vm.tracer.open) > 0 {
+		vm.traceExit(vm.tracer.open[len(vm.tracer.open)-1], true)
+	}
+}
+
+// traceError reports err in innermost evaluated node.
+func (vm *VM) traceError(program *Program, err error) {
+	t := vm.tracer
+	if vm.ctx.Err() != nil {
+		return
+	}
+	node, depth := program.Node, 0
+	if n := len(t.open); n > 0 {
+		node, depth = t.open[n-1].Node, n-1
+	}
+	t.emit(TraceEvent{Type: EventError, Node: node, Value: err, Depth: depth})
+}
@@ -3,6 +3,7 @@ package vm
 //go:generate sh -c "go run ./func_types > ./generated.go"
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -36,15 +37,48 @@ type VM struct {
 	curr         chan int
 	memory       uint
 	memoryBudget uint
+	variables    []any
+	depth        int // call depth of functions defined in expression
+	ctx          context.Context
+	covered      []bool // executed instructions, set only by RunCoverage
+	tracer       *tracer
+	calls        *int // function calls made, shared with functions defined in expression
+}
+
+// RunContext runs program with ctx, which is passed to builtins
+// supporting cancellation, like sleep.
+func RunContext(ctx context.Context, program *Program, env any) (any, error) {
+	if program == nil {
+		return nil, fmt.Errorf("program is nil")
+	}
+
+	vm := VM{ctx: ctx}
+	return vm.Run(program, env)
+}
+
+// RunCoverage runs program and marks executed instructions in covered,
+// which must have length of program.Bytecode. Marks of earlier runs are
+// kept, so covered may be shared by runs with different environments.
+func RunCoverage(program *Program, env any, covered []bool) (any, error) {
+	if program == nil {
+		return nil, fmt.Errorf("program is nil")
+	}
+	if len(covered) != len(program.Bytecode) {
+		return nil, fmt.Errorf("covered has length %d, expected %d", len(covered), len(program.Bytecode))
+	}
+
+	vm := VM{covered: covered}
+	return vm.execute(program, env)
 }
 
 type Scope struct {
-	Array   reflect.Value
-	Index   int
-	Len     int
-	Count   int
-	GroupBy map[any][]any
-	Acc     any
+	Array     reflect.Value
+	Index     int
+	Len       int
+	Count     int
+	GroupBy   map[any][]any
+	GroupKeys []any // keys of GroupBy in order of first appearance
+	Acc       any
 }
 
 func Debug() *VM {
@@ -56,17 +90,33 @@ func Debug() *VM {
 	return vm
 }
 
-func (vm *VM) Run(program *Program, env any) (_ any, err error) {
+func (vm *VM) Run(program *Program, env any) (any, error) {
+	if program.memo != nil {
+		return program.memo.run(env, func() (any, error) {
+			return vm.execute(program, env)
+		})
+	}
+	return vm.execute(program, env)
+}
+
+func (vm *VM) execute(program *Program, env any) (_ any, err error) {
 	defer func() {
 		if r := recover(); r != nil {
+			if program.OnPanic != nil {
+				vm.onPanic(program, r)
+			}
 			f := &file.Error{
-				Location: program.Locations[vm.ip-1],
-				Message:  fmt.Sprintf("%v", r),
+				Location:       program.Locations[vm.ip-1],
+				Message:        fmt.Sprintf("%v", r),
+				LocationPrefix: program.LocationPrefix,
 			}
 			if err, ok := r.(error); ok {
 				f.Wrap(err)
 			}
 			err = f.Bind(program.Source)
+			if vm.tracer != nil {
+				vm.traceError(program, err)
+			}
 		}
 	}()
 
@@ -83,7 +133,13 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 	vm.memoryBudget = MemoryBudget
 	vm.memory = 0
 	vm.ip = 0
+	vm.variables = make([]any, len(program.Variables))
+	vm.calls = new(int)
 
+	return vm.run(program, env), nil
+}
+
+func (vm *VM) run(program *Program, env any) any {
 	for vm.ip < len(program.Bytecode) {
 		if vm.debug {
 			<-vm.step
@@ -91,7 +147,16 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 
 		op := program.Bytecode[vm.ip]
 		arg := program.Arguments[vm.ip]
+		if vm.covered != nil {
+			vm.covered[vm.ip] = true
+		}
 		vm.ip += 1
+		if vm.tracer != nil {
+			vm.traceStep(vm.ip - 1)
+		}
+		if program.FunctionCallLimit > 0 && op >= OpCall && op <= OpCallSpread {
+			vm.countCall(program.FunctionCallLimit)
+		}
 
 		switch op {
 
@@ -108,19 +173,38 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			vm.pop()
 
 		case OpStore:
-			program.Variables[arg] = vm.pop()
+			vm.variables[arg] = vm.pop()
 
 		case OpLoadVar:
-			vm.push(program.Variables[arg])
+			vm.push(vm.variables[arg])
 
 		case OpLoadConst:
-			vm.push(runtime.Fetch(env, program.Constants[arg]))
+			if program.IdentifierResolver != nil {
+				vm.push(resolve(program, env, program.Constants[arg].(string)))
+			} else {
+				vm.push(program.fetch(env, program.Constants[arg]))
+			}
+			if program.OnIdentifierAccess != nil {
+				program.OnIdentifierAccess(program.Constants[arg].(string), vm.current())
+			}
 
 		case OpLoadField:
-			vm.push(runtime.FetchField(env, program.Constants[arg].(*runtime.Field)))
+			field := program.Constants[arg].(*runtime.Field)
+			vm.push(runtime.FetchField(env, field))
+			if program.OnIdentifierAccess != nil {
+				program.OnIdentifierAccess(strings.Join(field.Path, "."), vm.current())
+			}
 
 		case OpLoadFast:
-			vm.push(env.(map[string]any)[program.Constants[arg].(string)])
+			if m, ok := env.(map[string]any); ok {
+				vm.push(m[program.Constants[arg].(string)])
+			} else {
+				// Program compiled for map environment runs with *runtime.Environment.
+				vm.push(program.fetch(env, program.Constants[arg]))
+			}
+			if program.OnIdentifierAccess != nil {
+				program.OnIdentifierAccess(program.Constants[arg].(string), vm.current())
+			}
 
 		case OpLoadMethod:
 			vm.push(runtime.FetchMethod(env, program.Constants[arg].(*runtime.Method)))
@@ -131,7 +215,7 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 		case OpFetch:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Fetch(a, b))
+			vm.push(program.fetch(a, b))
 
 		case OpFetchField:
 			a := vm.pop()
@@ -164,7 +248,8 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 		case OpEqual:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Equal(a, b))
+			program.strict("==", a, b)
+			vm.push(program.equal(a, b))
 
 		case OpEqualInt:
 			b := vm.pop()
@@ -211,57 +296,71 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 		case OpIn:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.In(a, b))
+			vm.push(program.in(a, b))
 
 		case OpLess:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Less(a, b))
+			program.strict("<", a, b)
+			a, b = program.parseTimes(a, b)
+			vm.push(operate(program, "<", a, b, runtime.Less))
 
 		case OpMore:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.More(a, b))
+			program.strict(">", a, b)
+			a, b = program.parseTimes(a, b)
+			vm.push(operate(program, ">", a, b, runtime.More))
 
 		case OpLessOrEqual:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.LessOrEqual(a, b))
+			program.strict("<=", a, b)
+			a, b = program.parseTimes(a, b)
+			vm.push(operate(program, "<=", a, b, runtime.LessOrEqual))
 
 		case OpMoreOrEqual:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.MoreOrEqual(a, b))
+			program.strict(">=", a, b)
+			a, b = program.parseTimes(a, b)
+			vm.push(operate(program, ">=", a, b, runtime.MoreOrEqual))
 
 		case OpAdd:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Add(a, b))
+			program.strict("+", a, b)
+			vm.push(operate(program, "+", a, b, runtime.Add))
 
 		case OpSubtract:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Subtract(a, b))
+			program.strict("-", a, b)
+			vm.push(operate(program, "-", a, b, runtime.Subtract))
 
 		case OpMultiply:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Multiply(a, b))
+			program.strict("*", a, b)
+			vm.push(operate(program, "*", a, b, runtime.Multiply))
 
 		case OpDivide:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Divide(a, b))
+			program.strict("/", a, b)
+			vm.push(operate(program, "/", a, b, runtime.Divide))
 
 		case OpModulo:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Modulo(a, b))
+			program.strict("%", a, b)
+			vm.push(operate(program, "%", a, b, runtime.Modulo))
 
 		case OpExponent:
 			b := vm.pop()
 			a := vm.pop()
-			vm.push(runtime.Exponent(a, b))
+			program.strict("**", a, b)
+			vm.push(operate(program, "**", a, b, runtime.Exponent))
 
 		case OpRange:
 			b := vm.pop()
@@ -305,6 +404,19 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			a := vm.pop()
 			vm.push(strings.HasSuffix(a.(string), b.(string)))
 
+		case OpEqualFold:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(runtime.EqualFold(a, b))
+
+		case OpLoadContext:
+			vm.push(vm.context())
+
+		case OpSemVer:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(runtime.SemVerOp(arg>>1, a.(string), b.(string), arg&1 == 1))
+
 		case OpSlice:
 			from := vm.pop()
 			to := vm.pop()
@@ -312,11 +424,22 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			vm.push(runtime.Slice(node, from, to))
 
 		case OpCall:
-			fn := reflect.ValueOf(vm.pop())
+			callee := vm.pop()
+			if f, ok := callee.(*Func); ok {
+				vm.push(vm.callFunc(f, arg))
+				break
+			}
+			fn := reflect.ValueOf(callee)
 			size := arg
 			in := make([]reflect.Value, size)
 			for i := int(size) - 1; i >= 0; i-- {
 				param := vm.pop()
+				if f, ok := param.(*Func); ok {
+					if t := paramType(fn.Type(), i); t.Kind() == reflect.Func {
+						in[i] = f.makeFunc(t)
+						continue
+					}
+				}
 				if param == nil && reflect.TypeOf(param) == nil {
 					// In case of nil value and nil type use this hack,
 					// otherwise reflect.Call will panic on zero value.
@@ -339,7 +462,7 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			vm.push(out)
 
 		case OpCall1:
-			a := vm.pop()
+			a := native(vm.pop())
 			out, err := program.Functions[arg](a)
 			if err != nil {
 				panic(err)
@@ -347,8 +470,8 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			vm.push(out)
 
 		case OpCall2:
-			b := vm.pop()
-			a := vm.pop()
+			b := native(vm.pop())
+			a := native(vm.pop())
 			out, err := program.Functions[arg](a, b)
 			if err != nil {
 				panic(err)
@@ -356,9 +479,9 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			vm.push(out)
 
 		case OpCall3:
-			c := vm.pop()
-			b := vm.pop()
-			a := vm.pop()
+			c := native(vm.pop())
+			b := native(vm.pop())
+			a := native(vm.pop())
 			out, err := program.Functions[arg](a, b, c)
 			if err != nil {
 				panic(err)
@@ -370,7 +493,7 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			size := arg
 			in := make([]any, size)
 			for i := int(size) - 1; i >= 0; i-- {
-				in[i] = vm.pop()
+				in[i] = native(vm.pop())
 			}
 			out, err := fn(in...)
 			if err != nil {
@@ -388,11 +511,41 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			vm.push(fn(in...))
 
 		case OpCallTyped:
-			vm.push(vm.call(vm.pop(), arg))
+			fn := vm.pop()
+			if f, ok := fn.(*Func); ok {
+				// Function defined in expression passed around as a value.
+				vm.push(vm.callFunc(f, f.arity()))
+				break
+			}
+			vm.push(vm.call(fn, arg))
 
 		case OpCallBuiltin1:
 			vm.push(builtin.Builtins[arg].Fast(vm.pop()))
 
+		case OpCallSpread:
+			// Arguments are arg arrays, elements of which are passed
+			// as separate arguments.
+			groups := make([]any, arg)
+			for i := arg - 1; i >= 0; i-- {
+				groups[i] = vm.pop()
+			}
+			fn := vm.pop().(Function)
+			var in []any
+			for _, group := range groups {
+				v := reflect.ValueOf(group)
+				if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+					panic(fmt.Sprintf("cannot spread %T", group))
+				}
+				for j := 0; j < v.Len(); j++ {
+					in = append(in, v.Index(j).Interface())
+				}
+			}
+			out, err := fn(in...)
+			if err != nil {
+				panic(err)
+			}
+			vm.push(out)
+
 		case OpArray:
 			size := vm.pop().(int)
 			vm.memGrow(uint(size))
@@ -413,6 +566,21 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			}
 			vm.push(m)
 
+		case OpOrderedMap:
+			size := vm.pop().(int)
+			vm.memGrow(uint(size))
+			keys := make([]any, size)
+			values := make([]any, size)
+			for i := size - 1; i >= 0; i-- {
+				values[i] = vm.pop()
+				keys[i] = vm.pop()
+			}
+			m := runtime.NewOrderedMap(size)
+			for i := range keys {
+				m.Set(keys[i].(string), values[i])
+			}
+			vm.push(m)
+
 		case OpLen:
 			vm.push(runtime.Len(vm.current()))
 
@@ -459,6 +627,14 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 		case OpGetGroupBy:
 			vm.push(vm.Scope().GroupBy)
 
+		case OpGetOrderedGroupBy:
+			scope := vm.Scope()
+			groups := make([]runtime.GroupEntry, len(scope.GroupKeys))
+			for i, key := range scope.GroupKeys {
+				groups[i] = runtime.GroupEntry{Key: key, Values: scope.GroupBy[key]}
+			}
+			vm.push(groups)
+
 		case OpGetAcc:
 			vm.push(vm.Scope().Acc)
 
@@ -479,6 +655,9 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 			}
 			it := scope.Array.Index(scope.Index).Interface()
 			key := vm.pop()
+			if _, ok := scope.GroupBy[key]; !ok {
+				scope.GroupKeys = append(scope.GroupKeys, key)
+			}
 			scope.GroupBy[key] = append(scope.GroupBy[key], it)
 
 		case OpBegin:
@@ -492,6 +671,33 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 		case OpEnd:
 			vm.scopes = vm.scopes[:len(vm.scopes)-1]
 
+		case OpFunction:
+			variables := make([]any, len(vm.variables))
+			copy(variables, vm.variables)
+			// Elements of enclosing predicates, like #, are captured
+			// as they are now.
+			scopes := make([]*Scope, len(vm.scopes))
+			for i, scope := range vm.scopes {
+				s := *scope
+				scopes[i] = &s
+			}
+			vm.push(&Func{
+				FunctionInfo: program.Constants[arg].(*FunctionInfo),
+				program:      program,
+				env:          env,
+				variables:    variables,
+				scopes:       scopes,
+				depth:        vm.depth,
+				ctx:          vm.ctx,
+				calls:        vm.calls,
+			})
+
+		case OpReturn:
+			if vm.tracer != nil {
+				vm.traceEnd()
+			}
+			return vm.pop()
+
 		default:
 			panic(fmt.Sprintf("unknown bytecode %#x", op))
 		}
@@ -506,11 +712,79 @@ func (vm *VM) Run(program *Program, env any) (_ any, err error) {
 		close(vm.step)
 	}
 
+	if vm.tracer != nil {
+		vm.traceEnd()
+	}
+
 	if len(vm.stack) > 0 {
-		return vm.pop(), nil
+		return vm.pop()
 	}
 
-	return nil, nil
+	return nil
+}
+
+// paramType returns type of i-th argument of function type fn.
+func paramType(fn reflect.Type, i int) reflect.Type {
+	if fn.IsVariadic() && i >= fn.NumIn()-1 {
+		return fn.In(fn.NumIn() - 1).Elem()
+	}
+	return fn.In(i)
+}
+
+func (vm *VM) callFunc(f *Func, size int) any {
+	in := make([]any, size)
+	for i := size - 1; i >= 0; i-- {
+		in[i] = vm.pop()
+	}
+	return f.call(in, vm.depth)
+}
+
+// resolve looks up name with program.IdentifierResolver. Dotted paths
+// unknown to the resolver are resolved by fetching the last field from
+// the value of the rest of the path.
+func resolve(program *Program, env any, name string) any {
+	if value, ok := program.IdentifierResolver(name, env); ok {
+		return value
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return program.fetch(resolve(program, env, name[:i]), name[i+1:])
+	}
+	return nil
+}
+
+func (vm *VM) context() context.Context {
+	if vm.ctx == nil {
+		return context.Background()
+	}
+	return vm.ctx
+}
+
+// onPanic calls OnPanic hook of program if r was raised by a function
+// called by the last executed instruction.
+func (vm *VM) onPanic(program *Program, r any) {
+	ip := vm.ip - 1
+	if op := program.Bytecode[ip]; op < OpCall || op > OpCallSpread {
+		return
+	}
+	node := program.Node
+	for _, span := range program.Spans {
+		if span.Start <= ip && ip < span.End {
+			node = span.Node
+		}
+	}
+	program.OnPanic(r, node)
+}
+
+// countCall counts a function call, failing when more than limit calls
+// were made during evaluation.
+func (vm *VM) countCall(limit int) {
+	if vm.calls == nil {
+		vm.calls = new(int)
+	}
+	*vm.calls++
+	if *vm.calls > limit {
+		panic(fmt.Sprintf("function call limit of %d exceeded", limit))
+	}
 }
 
 func (vm *VM) push(value any) {
@@ -0,0 +1,74 @@
+package expr
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/expr/vm"
+)
+
+// Watcher re-evaluates program when caller notifies it about changed
+// values program depends on.
+type Watcher struct {
+	program  *vm.Program
+	env      func() any
+	onChange func(output any, err error)
+	debounce time.Duration
+	deps     []string
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+// Watch creates Watcher of program. After Notify calls, program is run with
+// env() and result is passed to onChange. Notifications received within
+// debounce window cause only one evaluation.
+func Watch(program *vm.Program, env func() any, onChange func(output any, err error), debounce time.Duration) *Watcher {
+	return &Watcher{
+		program:  program,
+		env:      env,
+		onChange: onChange,
+		debounce: debounce,
+		deps:     program.Dependencies(),
+	}
+}
+
+// Notify tells watcher value of key has changed. Program is re-evaluated
+// only if it depends on key.
+func (w *Watcher) Notify(key string) {
+	if _, ok := slices.BinarySearch(w.deps, key); !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.debounce, w.evaluate)
+	} else {
+		w.timer.Reset(w.debounce)
+	}
+}
+
+// Stop cancels pending evaluation, further notifications are ignored.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+func (w *Watcher) evaluate() {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.mu.Unlock()
+	w.onChange(Run(w.program, w.env()))
+}